@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/cache"
+	"mmfm-playback-go/internal/config"
+	"mmfm-playback-go/internal/player"
+	"mmfm-playback-go/pkg/types"
+	"text/tabwriter"
+)
+
+// cacheUsage lists the actions of the cache subcommand
+const cacheUsage = "usage: mmfm-playback cache ls|prune|flush|prefetch [-c config.json]"
+
+// runCache inspects and maintains the song cache of a config while the
+// player is running or not
+func runCache(args []string, out io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(out, cacheUsage)
+		return 2
+	}
+	action := args[0]
+	flags := flag.NewFlagSet("cache "+action, flag.ExitOnError)
+	confPath := configFlag(flags)
+	asJSON := flags.Bool("json", false, "print the listing as JSON (ls)")
+	flags.Parse(args[1:])
+
+	conf, err := config.NewConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	mp := player.NewMusicPlayer(conf)
+
+	switch action {
+	case "ls":
+		return cacheList(mp, out, *asJSON)
+	case "prune":
+		return cachePrune(mp, conf, out)
+	case "flush":
+		if err := mp.Cache().Flush(); err != nil {
+			fmt.Fprintln(out, "flush failed:", err)
+			return 1
+		}
+		fmt.Fprintln(out, "cache flushed")
+		return 0
+	case "prefetch":
+		return cachePrefetch(mp, conf, out)
+	}
+	fmt.Fprintln(out, cacheUsage)
+	return 2
+}
+
+// fileCache returns the file cache of the player, the other storages can
+// not be listed
+func fileCache(mp *player.MusicPlayer, out io.Writer) (*cache.FileCache, bool) {
+	fc, ok := mp.Cache().(*cache.FileCache)
+	if !ok {
+		fmt.Fprintln(out, "only the file cache can be listed and pruned")
+	}
+	return fc, ok
+}
+
+// cacheList prints the cache entries with the URLs they were fetched from,
+// the least recently used first
+func cacheList(mp *player.MusicPlayer, out io.Writer, asJSON bool) int {
+	fc, ok := fileCache(mp, out)
+	if !ok {
+		return 1
+	}
+	entries, err := fc.List()
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+
+	if asJSON {
+		data, _ := json.MarshalIndent(entries, "", "    ")
+		fmt.Fprintln(out, string(data))
+		return 0
+	}
+	var total int64
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "HASH\tSIZE\tACCESSED\tURL\t")
+	for _, entry := range entries {
+		url := entry.URL
+		if url == "" {
+			url = "-"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t\n", entry.Hash, formatSize(entry.Size), entry.AccessedAt.Format("2006-01-02 15:04"), url)
+		total += entry.Size
+	}
+	writer.Flush()
+	fmt.Fprintf(out, "%d entries, %s\n", len(entries), formatSize(total))
+	return 0
+}
+
+// cachePrune enforces the cache limits at once, without limits it removes
+// the entries which are neither in the playlist nor scheduled
+func cachePrune(mp *player.MusicPlayer, conf *config.PlaybackConfig, out io.Writer) int {
+	fc, ok := fileCache(mp, out)
+	if !ok {
+		return 1
+	}
+	limited := false
+	if conf.CacheOptions != nil {
+		maxSize, maxAge, _ := conf.CacheOptions.Limits()
+		limited = maxSize > 0 || maxAge > 0
+	}
+
+	songs, err := mp.LoadSongs(context.Background())
+	if err != nil && !limited {
+		fmt.Fprintln(out, "can not load the playlist, refusing to prune a cache without limits:", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(out, "can not load the playlist, only the scheduled audios are kept:", err)
+	}
+	keep := append(songs, scheduledSongs(conf)...)
+
+	before, err := fc.List()
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	if limited {
+		keys := make([]string, len(keep))
+		for i, song := range keep {
+			keys[i] = song.GetURL()
+		}
+		fc.Pin(keys...)
+		err = fc.Evict()
+	} else {
+		err = fc.Clean(keep)
+	}
+	if err != nil {
+		fmt.Fprintln(out, "prune failed:", err)
+		return 1
+	}
+
+	after, _ := fc.List()
+	var freed int64
+	for _, entry := range before {
+		freed += entry.Size
+	}
+	for _, entry := range after {
+		freed -= entry.Size
+	}
+	fmt.Fprintf(out, "removed %d entries, freed %s\n", len(before)-len(after), formatSize(freed))
+	return 0
+}
+
+// cachePrefetch downloads the songs of the playlist and the scheduled
+// audios which are not cached yet
+func cachePrefetch(mp *player.MusicPlayer, conf *config.PlaybackConfig, out io.Writer) int {
+	if options := conf.CacheOptions; options != nil && (options.Type == config.CacheMemory || options.Type == config.CacheNone) {
+		fmt.Fprintf(out, "the %s cache does not outlive the process, nothing to prefetch\n", options.Type)
+		return 1
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	songs, err := mp.LoadSongs(ctx)
+	if err != nil {
+		fmt.Fprintln(out, "can not load the playlist:", err)
+		return 1
+	}
+	c := mp.Cache()
+	// Fetch returns once the download is complete
+	if fc, ok := c.(*cache.FileCache); ok {
+		fc.SetMode(cache.ModeSync)
+	}
+
+	fetched, cached, failed := 0, 0, 0
+	for _, song := range append(songs, scheduledSongs(conf)...) {
+		if song.IsLive() {
+			continue
+		}
+		if c.Has(song.GetURL()) {
+			cached++
+			continue
+		}
+		fmt.Fprintln(out, "fetching", song.Name, song.GetURL())
+		if _, err := c.Fetch(ctx, song.GetURL()); err != nil {
+			if ctx.Err() != nil {
+				fmt.Fprintln(out, "interrupted")
+				return 1
+			}
+			fmt.Fprintln(out, "  failed:", err)
+			failed++
+			continue
+		}
+		fetched++
+	}
+
+	fmt.Fprintf(out, "%d fetched, %d already cached, %d failed\n", fetched, cached, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// scheduledSongs returns the scheduled audios of conf as songs
+func scheduledSongs(conf *config.PlaybackConfig) []*types.Song {
+	songs := make([]*types.Song, len(conf.ScheduledAudios))
+	for i, audio := range conf.ScheduledAudios {
+		songs[i] = &types.Song{Name: audio.Name, URL: audio.URL}
+	}
+	return songs
+}
+
+// formatSize formats bytes with a binary unit, e.g. 4.2 MB
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value, unit := float64(size), 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
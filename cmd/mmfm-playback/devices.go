@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/player"
+	"text/tabwriter"
+)
+
+// runDevices lists the audio outputs, the values go into audio.driver and
+// audio.device or the player.output command
+func runDevices(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("devices", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the devices as JSON")
+	flags.Parse(args)
+
+	devices := player.ListAudioDevices()
+	if *asJSON {
+		data, _ := json.MarshalIndent(devices, "", "    ")
+		fmt.Fprintln(out, string(data))
+		return 0
+	}
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "DRIVER\tDEVICE\tDESCRIPTION\t")
+	for _, device := range devices {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t\n", device.Driver, device.Device, device.Description)
+	}
+	writer.Flush()
+	return 0
+}
@@ -1,30 +1,71 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"mmfm-playback-go/internal/config"
-	"mmfm-playback-go/internal/logger"
-	"mmfm-playback-go/internal/player"
-	"runtime"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
-func main() {
-	confPath := flag.String("c", "config.json", "config json file")
-	flag.Parse()
+// command runs a subcommand with its arguments and returns the exit code
+type command struct {
+	run     func(args []string, out io.Writer) int
+	summary string
+}
 
-	runtime.GOMAXPROCS(runtime.NumCPU())
+// commands are the subcommands, run is the default
+var commands = map[string]command{
+	"run":      {runDaemon, "run the player (default)"},
+	"validate": {runValidate, "check a config file"},
+	"probe":    {runProbe, "print the media details of a URL or file"},
+	"cache":    {runCache, "ls, prune, flush or prefetch the song cache"},
+	"schedule": {runSchedule, "next: list the upcoming scheduled audios"},
+	"play":     {runPlay, "play a single URL or file"},
+	"devices":  {runDevices, "list the audio outputs"},
+	"status":   {runStatus, "print the status of a running player"},
+}
 
-	conf, err := config.NewConfig(*confPath)
-	if err != nil {
-		logger.Logger.Error(err)
-		return
+// commandOrder lists the subcommands in the usage
+var commandOrder = []string{"run", "validate", "probe", "cache", "schedule", "play", "devices", "status"}
+
+func main() {
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
-	logger.Logger.Info("mmfm playback config: ", conf)
 
-	mp := player.NewMusicPlayer(conf)
-	logger.Logger.Info("mmfm playback start.")
+	if name == "help" {
+		usage(os.Stdout)
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	os.Exit(cmd.run(args, os.Stdout))
+}
 
-	if err := mp.Start(); err != nil {
-		logger.Logger.Error(err)
+// usage prints the subcommands
+func usage(out io.Writer) {
+	fmt.Fprintln(out, "usage: mmfm-playback [command] [-c config.json] [arguments]")
+	fmt.Fprintln(out, "\ncommands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(out, "  %-9s %s\n", name, commands[name].summary)
 	}
 }
+
+// configFlag adds the -c flag selecting the config file
+func configFlag(flags *flag.FlagSet) *string {
+	return flags.String("c", "config.json", "config file, json, yaml or toml")
+}
+
+// interruptContext is done once the process is interrupted or terminated
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
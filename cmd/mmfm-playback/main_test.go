@@ -1,8 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"mmfm-playback-go/internal/config"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMain(t *testing.T) {
@@ -16,3 +23,103 @@ func TestMain(t *testing.T) {
 	// we'll just verify that the imports and basic functionality work by ensuring the build passes
 	// The actual functionality is tested in other packages
 }
+
+func TestRunValidate(t *testing.T) {
+	t.Setenv("MPLAYER_PATH", "")
+	dir := t.TempDir()
+	confPath := filepath.Join(dir, "config.json")
+	os.WriteFile(confPath, []byte(`{"ffmpeg": {"ffprobe": "ffprobe"}, "ws": "ws://mmfm", "web": "http://mmfm/song/get", "cache": "`+filepath.ToSlash(dir)+`", "crossfade": -1}`), 0644)
+
+	var out bytes.Buffer
+	if code := runValidate([]string{"-c", confPath}, &out); code != 1 {
+		t.Errorf("Expected exit code 1, got %d", code)
+	}
+	for _, field := range []string{"ffmpeg.mplayer", "crossfade"} {
+		if !strings.Contains(out.String(), field) {
+			t.Errorf("Expected a problem of %s, got %s", field, out.String())
+		}
+	}
+}
+
+// writeConfig writes a minimal valid config using dir as the cache
+func writeConfig(t *testing.T, dir string, extra string) string {
+	confPath := filepath.Join(dir, "config.json")
+	document := `{"ffmpeg": {"ffprobe": "ffprobe", "mplayer": "mplayer"}, "ws": "ws://mmfm", "web": "http://mmfm/song/get", "cache": "` + filepath.ToSlash(dir) + `"` + extra + `}`
+	if err := os.WriteFile(confPath, []byte(document), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return confPath
+}
+
+func TestRunCacheList(t *testing.T) {
+	dir := t.TempDir()
+	confPath := writeConfig(t, dir, "")
+	dataDir := filepath.Join(dir, "data")
+	os.MkdirAll(dataDir, 0755)
+	os.WriteFile(filepath.Join(dataDir, "0123abcd"), make([]byte, 2048), 0644)
+	os.WriteFile(filepath.Join(dataDir, "0123abcd.meta"), []byte(`{"url": "http://mmfm/song.mp3"}`), 0644)
+
+	var out bytes.Buffer
+	if code := runCache([]string{"ls", "-c", confPath}, &out); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "0123abcd") || !strings.Contains(out.String(), "http://mmfm/song.mp3") {
+		t.Errorf("Expected the entry with its URL, got %s", out.String())
+	}
+	if !strings.Contains(out.String(), "1 entries") {
+		t.Errorf("Expected the total, got %s", out.String())
+	}
+
+	out.Reset()
+	if code := runCache([]string{"flush", "-c", confPath}, &out); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, out.String())
+	}
+	if _, err := os.Stat(dataDir); !os.IsNotExist(err) {
+		t.Error("Expected the cache to be flushed")
+	}
+}
+
+func TestNextFires(t *testing.T) {
+	dir := t.TempDir()
+	confPath := writeConfig(t, dir, `, "scheduled_audios": [{"name": "close", "url": "close.mp3", "schedule": "21:00"}, {"name": "open", "url": "open.mp3", "schedule": "09:00"}], "operating_hours": {"weekdays": {"monday": "09:00-22:00"}}`)
+	conf, err := config.NewConfig(confPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-01-01 is a monday
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	fires := nextFires(conf, now, now.AddDate(0, 0, 1))
+	if len(fires) != 2 {
+		t.Fatalf("Expected 2 fires, got %d", len(fires))
+	}
+	if fires[0].audio.Name != "close" || fires[0].at.Hour() != 21 || fires[0].closed {
+		t.Errorf("Expected the close audio tonight first, got %+v", fires[0])
+	}
+	if fires[1].audio.Name != "open" || fires[1].at.Day() != 2 || !fires[1].closed {
+		t.Errorf("Expected the open audio tomorrow outside the operating hours, got %+v", fires[1])
+	}
+}
+
+func TestRunStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"paused": false}`))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	if code := runStatus([]string{"-addr", server.URL}, &out); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, out.String())
+	}
+	if out.String() != `{"paused": false}` {
+		t.Errorf("Expected the status, got %s", out.String())
+	}
+
+	if url := statusURL(":8080"); url != "http://127.0.0.1:8080/status" {
+		t.Errorf("Expected the local address, got %s", url)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/config"
+	"mmfm-playback-go/internal/player"
+)
+
+// runPlay plays a single URL or file with the configured backend, e.g. to
+// test the audio output of a new machine
+func runPlay(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("play", flag.ExitOnError)
+	confPath := configFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(out, "usage: mmfm-playback play [-c config.json] <file>")
+		return 2
+	}
+
+	conf, err := config.NewConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	fmt.Fprintln(out, "playing", flags.Arg(0))
+	if err := player.NewMusicPlayer(conf).PlayOnce(ctx, flags.Arg(0)); err != nil {
+		fmt.Fprintln(out, "play failed:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/config"
+	"mmfm-playback-go/internal/player"
+)
+
+// runProbe prints the media details of a URL or file as JSON, remote URLs
+// are requested with the configured HTTP headers
+func runProbe(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("probe", flag.ExitOnError)
+	confPath := configFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(out, "usage: mmfm-playback probe [-c config.json] <url>")
+		return 2
+	}
+
+	conf, err := config.NewConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	details, err := player.NewMusicPlayer(conf).Probe(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(out, "probe failed:", err)
+		return 1
+	}
+
+	data, _ := json.MarshalIndent(details, "", "    ")
+	fmt.Fprintln(out, string(data))
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/config"
+	"mmfm-playback-go/internal/logger"
+	"mmfm-playback-go/internal/player"
+	"runtime"
+)
+
+// runDaemon plays the playlist until the process is interrupted or
+// terminated
+func runDaemon(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	confPath := configFlag(flags)
+	printConfig := flags.Bool("print-config", false, "print the effective config with secrets masked and exit")
+	flags.Parse(args)
+
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	conf, err := config.NewConfig(*confPath)
+	if err != nil {
+		logger.Logger.Error(err)
+		return 1
+	}
+	if err := conf.Validate(); err != nil {
+		logger.Logger.Warning("configuration problems:\n", err)
+	}
+	masked, err := conf.Masked()
+	if err != nil {
+		logger.Logger.Error(err)
+		return 1
+	}
+	if *printConfig {
+		data, _ := json.MarshalIndent(masked, "", "    ")
+		fmt.Fprintln(out, string(data))
+		return 0
+	}
+	logger.Logger.Info("mmfm playback config: ", masked)
+
+	mp := player.NewMusicPlayer(conf)
+	config.NewWatcher(*confPath, mp.ApplyConfig).Start()
+	logger.Logger.Info("mmfm playback start.")
+
+	// SIGINT and SIGTERM stop the backends and save the position
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := mp.Run(ctx); err != nil {
+		logger.Logger.Error(err)
+		return 1
+	}
+	return 0
+}
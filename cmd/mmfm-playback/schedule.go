@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/config"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// fire is an upcoming playback of a scheduled audio
+type fire struct {
+	at     time.Time
+	audio  config.ScheduledAudio
+	closed bool // Outside the operating hours, the player skips it
+}
+
+// runSchedule lists the scheduled audios firing within the next days
+func runSchedule(args []string, out io.Writer) int {
+	if len(args) == 0 || args[0] != "next" {
+		fmt.Fprintln(out, "usage: mmfm-playback schedule next [-c config.json] [-days 1]")
+		return 2
+	}
+	flags := flag.NewFlagSet("schedule next", flag.ExitOnError)
+	confPath := configFlag(flags)
+	days := flags.Int("days", 1, "number of days to list")
+	flags.Parse(args[1:])
+
+	conf, err := config.NewConfig(*confPath)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	if len(conf.ScheduledAudios) == 0 {
+		fmt.Fprintln(out, "no scheduled audios configured")
+		return 0
+	}
+
+	now := time.Now()
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tIN\tNAME\tURL\t")
+	for _, f := range nextFires(conf, now, now.AddDate(0, 0, *days)) {
+		name := f.audio.Name
+		if f.closed {
+			name += " (closed, skipped)"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t\n", f.at.Format("Mon 2006-01-02 15:04"), formatUntil(f.at.Sub(now)), name, f.audio.URL)
+	}
+	writer.Flush()
+	return 0
+}
+
+// nextFires returns the fire times of the scheduled audios after now up to
+// until in chronological order
+func nextFires(conf *config.PlaybackConfig, now time.Time, until time.Time) []fire {
+	fires := []fire{}
+	for _, audio := range conf.ScheduledAudios {
+		at, err := config.NextClock(audio.Schedule, now)
+		for ; err == nil && !at.After(until); at, err = config.NextClock(audio.Schedule, at) {
+			closed := conf.OperatingHours != nil && !conf.OperatingHours.IsOpen(at)
+			fires = append(fires, fire{at: at, audio: audio, closed: closed})
+		}
+	}
+	sort.SliceStable(fires, func(i, j int) bool {
+		return fires[i].at.Before(fires[j].at)
+	})
+	return fires
+}
+
+// formatUntil formats a duration in hours and minutes, e.g. 3h5m
+func formatUntil(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+	return strings.TrimSuffix(d.Truncate(time.Minute).String(), "0s")
+}
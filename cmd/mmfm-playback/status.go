@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/config"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// statusTimeout bounds the request to the status API
+const statusTimeout = 5 * time.Second
+
+// runStatus prints the status of a running player from its local API
+func runStatus(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	confPath := configFlag(flags)
+	addr := flags.String("addr", "", "address of the status API, defaults to api of the config")
+	flags.Parse(args)
+
+	if *addr == "" {
+		conf, err := config.Load(*confPath)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return 1
+		}
+		if conf.API == "" {
+			fmt.Fprintln(out, "the status API is disabled, set api in", *confPath, "or pass -addr")
+			return 1
+		}
+		*addr = conf.API
+	}
+
+	client := &http.Client{Timeout: statusTimeout}
+	resp, err := client.Get(statusURL(*addr))
+	if err != nil {
+		fmt.Fprintln(out, "player not reachable:", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintln(out, "unexpected response:", resp.Status)
+		return 1
+	}
+	io.Copy(out, resp.Body)
+	return 0
+}
+
+// statusURL returns the status endpoint of the API listening on addr, an
+// address without host is the local machine
+func statusURL(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return strings.TrimSuffix(addr, "/") + "/status"
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/config"
+)
+
+// runValidate checks a config file, e.g. in the CI of a config repository.
+// It returns the exit code, 1 when the config has problems
+func runValidate(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	confPath := configFlag(flags)
+	flags.Parse(args)
+
+	conf, err := config.Load(*confPath)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	if err := conf.Validate(); err != nil {
+		var validationErr *config.ValidationError
+		if !errors.As(err, &validationErr) {
+			fmt.Fprintln(out, err)
+			return 1
+		}
+		fmt.Fprintf(out, "%s has %d problems:\n", *confPath, len(validationErr.Problems))
+		for _, problem := range validationErr.Problems {
+			fmt.Fprintln(out, "  "+problem.String())
+		}
+		return 1
+	}
+
+	fmt.Fprintln(out, *confPath, "is valid")
+	return 0
+}
@@ -3,8 +3,23 @@ module mmfm-playback-go
 go 1.21
 
 require (
-	github.com/gorilla/websocket v1.4.0 // indirect
+	github.com/BurntSushi/toml v1.6.0
 	github.com/graarh/golang-socketio v0.0.0-20170510162725-2c44953b9b5f
+	github.com/hajimehoshi/go-mp3 v0.3.4
+	github.com/jfreymuth/oggvorbis v1.0.5
 	github.com/joho/godotenv v1.5.1
+	github.com/mewkiz/flac v1.0.10
 	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7
+	github.com/yobert/alsa v0.0.0-20200618200352-d079056f5370
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/gorilla/websocket v1.4.0 // indirect
+	github.com/icza/bitio v1.1.0 // indirect
+	github.com/jfreymuth/vorbis v1.0.2 // indirect
+	github.com/mewkiz/pkg v0.0.0-20230226050401-4010bf0fec14 // indirect
 )
+
+// The fork adds the arm64 types of the ALSA kernel interface
+replace github.com/yobert/alsa => ./third_party/alsa
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"mmfm-playback-go/internal/logger"
+	"net/http"
+)
+
+// StatusProvider returns the state exposed by the status endpoint
+type StatusProvider func() interface{}
+
+// Server exposes the player state over a local HTTP API
+type Server struct {
+	addr   string
+	status StatusProvider
+}
+
+// NewServer creates a new Server instance
+func NewServer(addr string, status StatusProvider) *Server {
+	return &Server{
+		addr:   addr,
+		status: status,
+	}
+}
+
+// Handler returns the routes of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	return mux
+}
+
+// Start serves the API in background
+func (s *Server) Start() {
+	go func() {
+		logger.Logger.Info("status API listening on", s.addr)
+		if err := http.ListenAndServe(s.addr, s.Handler()); err != nil {
+			logger.Logger.Error(err)
+		}
+	}()
+}
+
+// handleStatus writes the current status as JSON
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(s.status()); err != nil {
+		logger.Logger.Error(err)
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusEndpoint(t *testing.T) {
+	server := NewServer("127.0.0.1:0", func() interface{} {
+		return map[string]interface{}{"volume": 40}
+	})
+
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+
+	var status map[string]interface{}
+	if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+		t.Fatal("Failed to decode status:", err)
+	}
+	if status["volume"] != float64(40) {
+		t.Errorf("Expected volume to be 40, got %v", status["volume"])
+	}
+}
@@ -0,0 +1,189 @@
+//go:build linux && (amd64 || arm || arm64)
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"github.com/yobert/alsa"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// alsaPeriod is the frames of an ALSA period, about 46ms at 44.1kHz
+const alsaPeriod = 2048
+
+// alsaSink plays to an ALSA hw device through the kernel interface, it
+// needs no alsa-lib so the binary stays static. The interface structs are
+// only defined for amd64 and arm. hw devices do not convert, the frames are
+// resampled when the device lacks the rate of a stream
+type alsaSink struct {
+	device string
+	cards  []*alsa.Card
+	pcm    *alsa.Device
+	rate   int
+	buffer int
+}
+
+// newALSASink creates the sink of an ALSA device name
+func newALSASink(device string) *alsaSink {
+	return &alsaSink{device: device}
+}
+
+// Open opens the device and negotiates 16-bit stereo at rate, or at the
+// common rates when the device lacks it
+func (s *alsaSink) Open(rate int) (int, error) {
+	card, number, err := parseALSADevice(s.device)
+	if err != nil {
+		return 0, err
+	}
+	cards, err := alsa.OpenCards()
+	if err != nil {
+		return 0, fmt.Errorf("alsa: %w", err)
+	}
+	s.cards = cards
+	pcm, err := findALSADevice(cards, card, number)
+	if err != nil {
+		s.Close()
+		return 0, err
+	}
+	if err := pcm.Open(); err != nil {
+		s.Close()
+		return 0, fmt.Errorf("alsa: %s: %w", pcm.Path, err)
+	}
+	s.pcm = pcm
+
+	if _, err := pcm.NegotiateChannels(2); err != nil {
+		s.Close()
+		return 0, fmt.Errorf("alsa: %s: %w", pcm.Path, err)
+	}
+	if s.rate, err = pcm.NegotiateRate(rate, 48000, 44100); err != nil {
+		s.Close()
+		return 0, fmt.Errorf("alsa: %s: %w", pcm.Path, err)
+	}
+	if _, err := pcm.NegotiateFormat(alsa.S16_LE); err != nil {
+		s.Close()
+		return 0, fmt.Errorf("alsa: %s: %w", pcm.Path, err)
+	}
+	if _, err := pcm.NegotiatePeriodSize(alsaPeriod); err != nil {
+		s.Close()
+		return 0, fmt.Errorf("alsa: %s: %w", pcm.Path, err)
+	}
+	if s.buffer, err = pcm.NegotiateBufferSize(alsaPeriod * 4); err != nil {
+		s.Close()
+		return 0, fmt.Errorf("alsa: %s: %w", pcm.Path, err)
+	}
+	if err := pcm.Prepare(); err != nil {
+		s.Close()
+		return 0, fmt.Errorf("alsa: %s: %w", pcm.Path, err)
+	}
+	return s.rate, nil
+}
+
+// Write plays pcm, an underrun after a slow read prepares the device again
+func (s *alsaSink) Write(pcm []byte) error {
+	if len(pcm) < FrameSize {
+		return nil
+	}
+	err := s.pcm.Write(pcm, len(pcm)/FrameSize)
+	if errors.Is(err, syscall.EPIPE) {
+		if err = s.pcm.Prepare(); err == nil {
+			err = s.pcm.Write(pcm, len(pcm)/FrameSize)
+		}
+	}
+	return err
+}
+
+// Drain waits for the length of the device buffer, closing the device would
+// drop it
+func (s *alsaSink) Drain() error {
+	if s.rate > 0 {
+		time.Sleep(time.Duration(s.buffer) * time.Second / time.Duration(s.rate))
+	}
+	return nil
+}
+
+// Close closes the device and the cards
+func (s *alsaSink) Close() error {
+	if s.pcm != nil {
+		s.pcm.Close()
+		s.pcm = nil
+	}
+	alsa.CloseCards(s.cards)
+	s.cards = nil
+	return nil
+}
+
+// parseALSADevice returns the card and device numbers of hw:CARD=id,DEV=n,
+// hw:card,device or hw:card, an empty name selects the first playback device
+// of any card with -1
+func parseALSADevice(name string) (int, int, error) {
+	if name == "" || name == "default" {
+		return -1, -1, nil
+	}
+	spec, ok := strings.CutPrefix(name, "hw:")
+	if !ok {
+		spec, ok = strings.CutPrefix(name, "plughw:")
+	}
+	if !ok {
+		return 0, 0, fmt.Errorf("alsa: unsupported device %q, use a hw device such as hw:CARD=Device,DEV=0", name)
+	}
+
+	card, device := -1, 0
+	for i, part := range strings.Split(spec, ",") {
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			value = key
+			key = []string{"CARD", "DEV"}[min(i, 1)]
+		}
+		switch key {
+		case "CARD":
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				// Card ids link to the card directories, e.g. /proc/asound/Device -> card1
+				link, linkErr := os.Readlink(filepath.Join("/proc/asound", value))
+				if linkErr != nil {
+					return 0, 0, fmt.Errorf("alsa: unknown card %q", value)
+				}
+				if number, err = strconv.Atoi(strings.TrimPrefix(filepath.Base(link), "card")); err != nil {
+					return 0, 0, fmt.Errorf("alsa: unknown card %q", value)
+				}
+			}
+			card = number
+		case "DEV":
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return 0, 0, fmt.Errorf("alsa: invalid device %q", value)
+			}
+			device = number
+		}
+	}
+	return card, device, nil
+}
+
+// findALSADevice returns the playback device of the card numbers, -1 takes
+// the first one
+func findALSADevice(cards []*alsa.Card, card int, number int) (*alsa.Device, error) {
+	for _, c := range cards {
+		if card >= 0 && c.Number != card {
+			continue
+		}
+		devices, err := c.Devices()
+		if err != nil {
+			return nil, fmt.Errorf("alsa: %w", err)
+		}
+		for _, device := range devices {
+			if device.Type == alsa.PCM && device.Play && (card < 0 || device.Number == number) {
+				return device, nil
+			}
+		}
+	}
+	if card < 0 {
+		return nil, errors.New("alsa: no playback device")
+	}
+	return nil, fmt.Errorf("alsa: no playback device hw:%d,%d", card, number)
+}
@@ -0,0 +1,36 @@
+//go:build !linux || !(amd64 || arm || arm64)
+
+package audio
+
+import (
+	"errors"
+)
+
+// alsaSink reports that ALSA is not available, the kernel interface is
+// only implemented for linux on amd64, arm and arm64
+type alsaSink struct{}
+
+// newALSASink creates the sink of an ALSA device name
+func newALSASink(device string) *alsaSink {
+	return &alsaSink{}
+}
+
+// Open fails as there is no ALSA
+func (s *alsaSink) Open(rate int) (int, error) {
+	return 0, errors.New("alsa: only supported on linux amd64, arm and arm64, use the wav or null output")
+}
+
+// Write does nothing
+func (s *alsaSink) Write(pcm []byte) error {
+	return nil
+}
+
+// Drain does nothing
+func (s *alsaSink) Drain() error {
+	return nil
+}
+
+// Close does nothing
+func (s *alsaSink) Close() error {
+	return nil
+}
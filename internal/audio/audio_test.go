@@ -0,0 +1,263 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ramp returns the 16-bit value of a frame and channel of the test signals
+func ramp(i int, channel int) int16 {
+	return int16((i%1000)*10 - channel*5000)
+}
+
+// wavData builds a 16-bit WAV file of the ramp
+func wavData(rate int, channels int, frames int) []byte {
+	var buf bytes.Buffer
+	size := frames * channels * 2
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+size))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, []uint32{16})
+	binary.Write(&buf, binary.LittleEndian, []uint16{wavPCM, uint16(channels)})
+	binary.Write(&buf, binary.LittleEndian, []uint32{uint32(rate), uint32(rate * channels * 2)})
+	binary.Write(&buf, binary.LittleEndian, []uint16{uint16(channels * 2), 16})
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(size))
+	for i := 0; i < frames; i++ {
+		for channel := 0; channel < channels; channel++ {
+			binary.Write(&buf, binary.LittleEndian, ramp(i, channel))
+		}
+	}
+	return buf.Bytes()
+}
+
+// flacData encodes the ramp as verbatim FLAC frames
+func flacData(t *testing.T, rate int, frames int) []byte {
+	path := filepath.Join(t.TempDir(), "ramp.flac")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &meta.StreamInfo{BlockSizeMin: 4096, BlockSizeMax: 4096, SampleRate: uint32(rate), NChannels: 2, BitsPerSample: 16}
+	enc, err := flac.NewEncoder(file, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start := 0; start < frames; start += 4096 {
+		size := min(4096, frames-start)
+		subframes := make([]*frame.Subframe, 2)
+		for channel := range subframes {
+			samples := make([]int32, size)
+			for i := range samples {
+				samples[i] = int32(ramp(start+i, channel))
+			}
+			subframes[channel] = &frame.Subframe{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: samples, NSamples: size}
+		}
+		header := frame.Header{HasFixedBlockSize: true, BlockSize: uint16(size), SampleRate: uint32(rate), Channels: frame.ChannelsLR, BitsPerSample: 16}
+		if err := enc.WriteFrame(&frame.Frame{Header: header, Subframes: subframes}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	return data
+}
+
+// mp3Data builds silent MPEG-1 layer III frames, 128kbps at 44.1kHz
+func mp3Data(frames int) []byte {
+	frameData := make([]byte, 417)
+	copy(frameData, []byte{0xff, 0xfb, 0x90, 0x00})
+	return bytes.Repeat(frameData, frames)
+}
+
+// stream hides the Seeker of r
+type stream struct {
+	io.Reader
+}
+
+// checkRamp checks that samples continue the ramp at frame
+func checkRamp(t *testing.T, name string, samples []float32, frame int) {
+	for i := 0; i < len(samples)/2; i++ {
+		for channel := 0; channel < 2; channel++ {
+			expected := float32(ramp(frame+i, channel)) / 32768
+			if math.Abs(float64(samples[2*i+channel]-expected)) > 0.001 {
+				t.Fatalf("%s: expected sample %d:%d to be %f, got %f", name, frame+i, channel, expected, samples[2*i+channel])
+			}
+		}
+	}
+}
+
+func TestDecoders(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"wav":  wavData(8000, 2, 16000),
+		"flac": flacData(t, 8000, 16000),
+	} {
+		decoder, err := NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		format := decoder.Format()
+		if format.Name != name || format.SampleRate != 8000 || format.Channels != 2 || format.BitsPerSample != 16 {
+			t.Errorf("%s: unexpected format %+v", name, format)
+		}
+		if duration := decoder.Duration(); duration != 2 {
+			t.Errorf("%s: expected a duration of 2s, got %f", name, duration)
+		}
+
+		samples := make([]float32, 200)
+		n, err := decoder.Read(samples)
+		if err != nil || n != 200 {
+			t.Fatalf("%s: expected 200 samples, got %d (%v)", name, n, err)
+		}
+		checkRamp(t, name, samples, 0)
+
+		if err := decoder.Seek(1.5); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		n, _ = decoder.Read(samples)
+		checkRamp(t, name, samples[:n], 12000)
+
+		// Streams which can not seek decode up to the position
+		decoder, err = NewDecoder(stream{bytes.NewReader(data)})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := decoder.Seek(1.5); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		n, _ = decoder.Read(samples)
+		checkRamp(t, name, samples[:n], 12000)
+
+		total := 12000*2 + n
+		for err == nil {
+			n, err = decoder.Read(samples)
+			total += n
+		}
+		if err != io.EOF || total != 32000 {
+			t.Errorf("%s: expected 32000 samples until EOF, got %d (%v)", name, total, err)
+		}
+	}
+
+	decoder, err := NewDecoder(bytes.NewReader(mp3Data(20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := decoder.Format(); format.Name != "mp3" || format.SampleRate != 44100 || format.Channels != 2 {
+		t.Errorf("Unexpected mp3 format %+v", format)
+	}
+	if duration := decoder.Duration(); math.Abs(duration-20*1152/44100.0) > 0.001 {
+		t.Errorf("Expected the duration of 20 mp3 frames, got %f", duration)
+	}
+	if err := decoder.Seek(0.2); err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]float32, 1024)
+	if n, err := decoder.Read(samples); n != 1024 || err != nil || samples[0] != 0 {
+		t.Errorf("Expected silence, got %d samples (%v)", n, err)
+	}
+
+	// Streams which can not seek are estimated from their size
+	tag := append([]byte("ID3\x03\x00\x00\x00\x00\x01\x00"), make([]byte, 128)...)
+	data := append(tag, mp3Data(20)...)
+	decoder, err = NewDecoder(stream{bytes.NewReader(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if duration := EstimateDuration(decoder, int64(len(data))); math.Abs(duration-20*417*8/128000.0) > 0.001 {
+		t.Errorf("Expected the duration of 20 frames at 128kbps, got %f", duration)
+	}
+	if duration := EstimateDuration(decoder, -1); duration != 0 {
+		t.Errorf("Expected no duration without a size, got %f", duration)
+	}
+
+	// The Xing header counts the frames
+	xing := mp3Data(20)
+	copy(xing[36:], "Xing\x00\x00\x00\x01\x00\x00\x03\xe8")
+	decoder, err = NewDecoder(stream{bytes.NewReader(xing)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if duration := EstimateDuration(decoder, int64(len(xing))); math.Abs(duration-1000*1152/44100.0) > 0.001 {
+		t.Errorf("Expected the duration of the 1000 Xing frames, got %f", duration)
+	}
+
+	if _, err := NewDecoder(bytes.NewReader([]byte("ID4 not audio"))); err != ErrFormat {
+		t.Errorf("Expected ErrFormat, got %v", err)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	sink, err := NewSink(DriverWAV, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rate, err := sink.Open(8000)
+	if err != nil || rate != 8000 {
+		t.Fatalf("Expected the wav sink to accept 8000Hz, got %d (%v)", rate, err)
+	}
+	samples := make([]float32, 1600)
+	for i := range samples {
+		samples[i] = float32(ramp(i/2, i%2)) / 32768
+	}
+	started := time.Now()
+	if err := sink.Write(Encode(nil, samples, 2, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected 800 frames to take 100ms, took %v", elapsed)
+	}
+	sink.Close()
+
+	file, _ := os.Open(path)
+	defer file.Close()
+	decoder, err := NewDecoder(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if duration := decoder.Duration(); duration != 0.1 {
+		t.Errorf("Expected a recording of 0.1s, got %f", duration)
+	}
+	n, _ := decoder.Read(samples)
+	checkRamp(t, "sink", samples[:n], 0)
+
+	if _, err := NewSink("pulse", ""); err == nil {
+		t.Error("Expected pulse to be unsupported")
+	}
+	if _, err := NewSink(DriverWAV, ""); err == nil {
+		t.Error("Expected the wav sink to require a path")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	// Mono is played on both sides, the gain is clipped
+	pcm := Encode(nil, []float32{0.5, -0.75}, 1, 2)
+	expected := []int16{32767, 32767, -32767, -32767}
+	for i, value := range expected {
+		if sample := int16(binary.LittleEndian.Uint16(pcm[2*i:])); sample != value {
+			t.Errorf("Expected sample %d to be %d, got %d", i, value, sample)
+		}
+	}
+
+	resampler := NewResampler(8000, 16000)
+	out := resampler.Resample(nil, Encode(nil, []float32{0, 0, 0.5, 0.5}, 2, 1))
+	out = resampler.Resample(out, Encode(nil, []float32{1, 1}, 2, 1))
+	// The last frame is interpolated with the next call
+	if frames := len(out) / FrameSize; frames != 4 {
+		t.Fatalf("Expected 3 frames to become 4, got %d", frames)
+	}
+	if sample := int16(binary.LittleEndian.Uint16(out[3*FrameSize:])); sample < 24000 || sample > 25000 {
+		t.Errorf("Expected the frame between 0.5 and 1 to be interpolated, got %d", sample)
+	}
+}
@@ -0,0 +1,144 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// ErrFormat is returned for streams which are not MP3, FLAC, Ogg Vorbis or
+// WAV
+var ErrFormat = errors.New("unsupported audio format")
+
+// Format describes a decoded stream
+type Format struct {
+	Name          string // mp3, flac, vorbis or wav
+	SampleRate    int
+	Channels      int
+	BitsPerSample int // Bits of the encoded samples, 0 for lossy formats
+}
+
+// Decoder decodes an audio stream into interleaved float samples
+type Decoder interface {
+	// Format returns the format of the stream
+	Format() Format
+	// Duration returns the length in seconds, 0 when it is unknown because
+	// the stream can not seek
+	Duration() float64
+	// Read decodes interleaved samples in the range [-1, 1] into buf, its
+	// length has to be a multiple of the channel count. io.EOF ends the
+	// stream
+	Read(buf []float32) (int, error)
+	// Seek moves to the second, streams which can not seek are decoded up
+	// to it
+	Seek(second float64) error
+}
+
+// NewDecoder detects the format of r from its first bytes. Readers which
+// implement io.Seeker know their duration and seek, others are decoded from
+// the start
+func NewDecoder(r io.Reader) (Decoder, error) {
+	var src io.Reader
+	var header []byte
+	if rs, ok := r.(io.ReadSeeker); ok {
+		bs := newBufferedSeeker(rs)
+		header, _ = bs.buf.Peek(12)
+		src = bs
+	} else {
+		br := bufio.NewReaderSize(r, bufferSize)
+		header, _ = br.Peek(12)
+		src = br
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return newFLAC(src)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return newVorbis(src)
+	case len(header) == 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:], []byte("WAVE")):
+		return newWAV(src)
+	case bytes.HasPrefix(header, []byte("ID3")), len(header) >= 2 && header[0] == 0xff && header[1]&0xe0 == 0xe0:
+		return newMP3(src, id3Size(header))
+	}
+	return nil, ErrFormat
+}
+
+// bitRater is implemented by the decoders of compressed streams, it returns
+// the average bits per second and the bytes before the audio, 0 bits if
+// unknown
+type bitRater interface {
+	bitRate() (int, int64)
+}
+
+// EstimateDuration returns the duration of d, a stream which can not seek
+// is estimated from its size in bytes, -1 if unknown, and its bit rate. 0 is
+// returned when the duration stays unknown
+func EstimateDuration(d Decoder, size int64) float64 {
+	if duration := d.Duration(); duration > 0 || size <= 0 {
+		return duration
+	}
+	if br, ok := d.(bitRater); ok {
+		if bits, offset := br.bitRate(); bits > 0 && size > offset {
+			return float64(size-offset) * 8 / float64(bits)
+		}
+	}
+	return 0
+}
+
+// bufferSize is the read buffer of the decoders, the decoding libraries
+// read a few bytes at a time
+const bufferSize = 64 * 1024
+
+// bufferedSeeker buffers the reads of a file without losing track of its
+// position
+type bufferedSeeker struct {
+	rs  io.ReadSeeker
+	buf *bufio.Reader
+}
+
+// newBufferedSeeker creates a bufferedSeeker reading rs
+func newBufferedSeeker(rs io.ReadSeeker) *bufferedSeeker {
+	return &bufferedSeeker{rs: rs, buf: bufio.NewReaderSize(rs, bufferSize)}
+}
+
+// Read reads from the buffer
+func (bs *bufferedSeeker) Read(p []byte) (int, error) {
+	return bs.buf.Read(p)
+}
+
+// Seek seeks the file and drops the buffer, positions relative to the
+// current one account for the buffered bytes
+func (bs *bufferedSeeker) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekCurrent {
+		offset -= int64(bs.buf.Buffered())
+	}
+	pos, err := bs.rs.Seek(offset, whence)
+	bs.buf.Reset(bs.rs)
+	return pos, err
+}
+
+// discard decodes and drops the samples before a position of a stream
+// which can not seek
+func discard(d Decoder, samples int) error {
+	buf := make([]float32, 4096*d.Format().Channels)
+	for samples > 0 {
+		if samples < len(buf) {
+			buf = buf[:samples]
+		}
+		n, err := d.Read(buf)
+		samples -= n
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// samplesAt returns the interleaved sample count of a stream up to second
+func samplesAt(f Format, second float64) int {
+	return int(second*float64(f.SampleRate)) * f.Channels
+}
@@ -0,0 +1,89 @@
+package audio
+
+import (
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"io"
+)
+
+// flacDecoder decodes FLAC frame by frame
+type flacDecoder struct {
+	stream   *flac.Stream
+	seekable bool
+	frame    *frame.Frame
+	offset   int // Next inter-channel sample of the frame
+	scale    float32
+}
+
+// newFLAC creates a decoder of the FLAC stream r
+func newFLAC(r io.Reader) (*flacDecoder, error) {
+	var stream *flac.Stream
+	var err error
+	rs, seekable := r.(io.ReadSeeker)
+	if seekable {
+		stream, err = flac.NewSeek(rs)
+	} else {
+		stream, err = flac.New(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &flacDecoder{
+		stream:   stream,
+		seekable: seekable,
+		scale:    float32(int64(1) << (stream.Info.BitsPerSample - 1)),
+	}, nil
+}
+
+// Format returns the format of the stream info block
+func (d *flacDecoder) Format() Format {
+	info := d.stream.Info
+	return Format{Name: "flac", SampleRate: int(info.SampleRate), Channels: int(info.NChannels), BitsPerSample: int(info.BitsPerSample)}
+}
+
+// Duration returns the sample count of the stream info block, encoders
+// which stream may leave it unknown
+func (d *flacDecoder) Duration() float64 {
+	return float64(d.stream.Info.NSamples) / float64(d.stream.Info.SampleRate)
+}
+
+// Read interleaves the channels of the frames into buf
+func (d *flacDecoder) Read(buf []float32) (int, error) {
+	channels := int(d.stream.Info.NChannels)
+	n := 0
+	for n+channels <= len(buf) {
+		if d.frame == nil || d.offset >= int(d.frame.BlockSize) {
+			next, err := d.stream.ParseNext()
+			if err != nil {
+				if n > 0 && err == io.EOF {
+					return n, nil
+				}
+				return n, err
+			}
+			d.frame, d.offset = next, 0
+		}
+		for d.offset < int(d.frame.BlockSize) && n+channels <= len(buf) {
+			for _, subframe := range d.frame.Subframes {
+				buf[n] = float32(subframe.Samples[d.offset]) / d.scale
+				n++
+			}
+			d.offset++
+		}
+	}
+	return n, nil
+}
+
+// Seek moves to the frame containing second and skips the samples of the
+// frame before it
+func (d *flacDecoder) Seek(second float64) error {
+	if !d.seekable {
+		return discard(d, samplesAt(d.Format(), second))
+	}
+	sample := uint64(second * float64(d.stream.Info.SampleRate))
+	start, err := d.stream.Seek(sample)
+	if err != nil {
+		return err
+	}
+	d.frame = nil
+	return discard(d, int(sample-start)*int(d.stream.Info.NChannels))
+}
@@ -0,0 +1,191 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/hajimehoshi/go-mp3"
+	"io"
+)
+
+// mp3HeaderSize is the bytes of the first frame read for its bit rate and
+// its Xing header
+const mp3HeaderSize = 64
+
+// mp3BitRates are the layer III bit rates in kbps of MPEG-1 and of MPEG-2
+// and 2.5 by the index of the frame header
+var mp3BitRates = [2][15]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// mp3Decoder decodes MPEG audio layer III, go-mp3 always produces 16-bit
+// stereo
+type mp3Decoder struct {
+	dec      *mp3.Decoder
+	seekable bool
+	buf      []byte
+	// tagSize, bits and frames describe streams which can not seek, frames
+	// is the count of the Xing header, 0 if missing
+	tagSize int64
+	bits    int
+	frames  int
+}
+
+// newMP3 creates a decoder of the MP3 stream r, seekable streams are
+// scanned for their frames to know the duration. The first frame of other
+// streams, after the ID3 tag of tagSize bytes, is kept for its bit rate
+func newMP3(r io.Reader, tagSize int64) (*mp3Decoder, error) {
+	_, seekable := r.(io.Seeker)
+	var tap *headerTap
+	if !seekable {
+		tap = &headerTap{reader: r, skip: tagSize}
+		r = tap
+	}
+	dec, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	d := &mp3Decoder{dec: dec, seekable: seekable, tagSize: tagSize}
+	if tap != nil {
+		d.bits, d.frames = parseMP3Header(tap.header)
+	}
+	return d, nil
+}
+
+// id3Size returns the bytes of the ID3v2 tag starting header, 0 without one
+func id3Size(header []byte) int64 {
+	if len(header) < 10 || !bytes.HasPrefix(header, []byte("ID3")) {
+		return 0
+	}
+	size := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+	size += 10
+	if header[5]&0x10 != 0 {
+		// Footer
+		size += 10
+	}
+	return size
+}
+
+// parseMP3Header returns the bit rate of the layer III frame header and the
+// frame count of its Xing or Info header, 0 when unknown
+func parseMP3Header(header []byte) (int, int) {
+	if len(header) < 4 || header[0] != 0xff || header[1]&0xe0 != 0xe0 || header[1]>>1&0x03 != 0x01 {
+		return 0, 0
+	}
+	// The side information follows the header, its size depends on the
+	// version, 3 is MPEG-1, and on the channel mode, 3 is mono
+	mono := header[3]>>6 == 3
+	table, sideInfo := 0, 32
+	if mono {
+		sideInfo = 17
+	}
+	if header[1]>>3&0x03 != 3 {
+		table, sideInfo = 1, 17
+		if mono {
+			sideInfo = 9
+		}
+	}
+	index := int(header[2] >> 4)
+	if index == 0 || index >= len(mp3BitRates[table]) {
+		return 0, 0
+	}
+	bits := mp3BitRates[table][index] * 1000
+
+	offset := 4 + sideInfo
+	if header[1]&0x01 == 0 {
+		// CRC
+		offset += 2
+	}
+	frames := 0
+	if len(header) >= offset+12 {
+		tag := string(header[offset : offset+4])
+		flags := binary.BigEndian.Uint32(header[offset+4:])
+		if (tag == "Xing" || tag == "Info") && flags&0x01 != 0 {
+			frames = int(binary.BigEndian.Uint32(header[offset+8:]))
+		}
+	}
+	return bits, frames
+}
+
+// headerTap keeps the first bytes of a stream after skip bytes
+type headerTap struct {
+	reader io.Reader
+	skip   int64
+	header []byte
+}
+
+// Read implements io.Reader
+func (ht *headerTap) Read(p []byte) (int, error) {
+	n, err := ht.reader.Read(p)
+	data := p[:n]
+	if ht.skip > 0 {
+		skipped := min(ht.skip, int64(len(data)))
+		ht.skip -= skipped
+		data = data[skipped:]
+	}
+	if missing := mp3HeaderSize - len(ht.header); missing > 0 && len(data) > 0 {
+		ht.header = append(ht.header, data[:min(missing, len(data))]...)
+	}
+	return n, err
+}
+
+// Format returns the format of the stream
+func (d *mp3Decoder) Format() Format {
+	return Format{Name: "mp3", SampleRate: d.dec.SampleRate(), Channels: 2}
+}
+
+// Duration returns the length of the scanned frames, or of the frames
+// counted by the Xing header of a stream which can not seek
+func (d *mp3Decoder) Duration() float64 {
+	if d.dec.Length() <= 0 {
+		if d.frames > 0 {
+			return float64(d.frames*d.samplesPerFrame()) / float64(d.dec.SampleRate())
+		}
+		return 0
+	}
+	return float64(d.dec.Length()/4) / float64(d.dec.SampleRate())
+}
+
+// samplesPerFrame returns the samples of a frame, MPEG-2 and 2.5 frames
+// have half of the MPEG-1 ones
+func (d *mp3Decoder) samplesPerFrame() int {
+	if d.dec.SampleRate() >= 32000 {
+		return 1152
+	}
+	return 576
+}
+
+// bitRate returns the bit rate of the first frame
+func (d *mp3Decoder) bitRate() (int, int64) {
+	return d.bits, d.tagSize
+}
+
+// Read decodes the 16-bit samples into buf
+func (d *mp3Decoder) Read(buf []float32) (int, error) {
+	if cap(d.buf) < len(buf)*2 {
+		d.buf = make([]byte, len(buf)*2)
+	}
+	data := d.buf[:len(buf)*2]
+	n, err := io.ReadFull(d.dec, data)
+	if err == io.ErrUnexpectedEOF {
+		// The end of the stream is returned by the next Read
+		err = nil
+	}
+	n -= n % 4
+	for i := 0; i < n/2; i++ {
+		buf[i] = float32(int16(uint16(data[2*i])|uint16(data[2*i+1])<<8)) / 32768
+	}
+	if n == 0 && err == nil {
+		err = io.EOF
+	}
+	return n / 2, err
+}
+
+// Seek moves to the frame containing second
+func (d *mp3Decoder) Seek(second float64) error {
+	if !d.seekable {
+		return discard(d, samplesAt(d.Format(), second))
+	}
+	_, err := d.dec.Seek(int64(second*float64(d.dec.SampleRate()))*4, io.SeekStart)
+	return err
+}
@@ -0,0 +1,68 @@
+package audio
+
+// Encode converts interleaved samples of the channel count into the 16-bit
+// stereo frames of a Sink, appending them to dst. Mono is played on both
+// sides and channels beyond the first two are dropped, the samples are
+// scaled by gain and clipped
+func Encode(dst []byte, samples []float32, channels int, gain float32) []byte {
+	for i := 0; i+channels <= len(samples); i += channels {
+		left := samples[i]
+		right := left
+		if channels > 1 {
+			right = samples[i+1]
+		}
+		dst = appendSample(dst, left*gain)
+		dst = appendSample(dst, right*gain)
+	}
+	return dst
+}
+
+// appendSample appends a clipped 16-bit little endian sample
+func appendSample(dst []byte, sample float32) []byte {
+	if sample > 1 {
+		sample = 1
+	} else if sample < -1 {
+		sample = -1
+	}
+	value := int16(sample * 32767)
+	return append(dst, byte(value), byte(value>>8))
+}
+
+// Resampler converts 16-bit stereo frames between sample rates by linear
+// interpolation, for outputs which do not support the rate of a stream
+type Resampler struct {
+	step float64 // Input frames per output frame
+	pos  float64 // Position of the next output frame, 0 is the last frame of the previous call
+	last [2]int16
+}
+
+// NewResampler creates a Resampler from one sample rate to another
+func NewResampler(from int, to int) *Resampler {
+	return &Resampler{step: float64(from) / float64(to), pos: 1}
+}
+
+// Resample appends the frames of pcm at the target rate to dst
+func (r *Resampler) Resample(dst []byte, pcm []byte) []byte {
+	frames := len(pcm) / FrameSize
+	frame := func(i int, channel int) float64 {
+		if i == 0 {
+			return float64(r.last[channel])
+		}
+		offset := (i-1)*FrameSize + channel*2
+		return float64(int16(uint16(pcm[offset]) | uint16(pcm[offset+1])<<8))
+	}
+	for ; int(r.pos) < frames; r.pos += r.step {
+		i := int(r.pos)
+		fraction := r.pos - float64(i)
+		for channel := 0; channel < 2; channel++ {
+			from, to := frame(i, channel), frame(i+1, channel)
+			value := int16(from + (to-from)*fraction)
+			dst = append(dst, byte(value), byte(value>>8))
+		}
+	}
+	if frames > 0 {
+		r.pos -= float64(frames)
+		r.last = [2]int16{int16(frame(frames, 0)), int16(frame(frames, 1))}
+	}
+	return dst
+}
@@ -0,0 +1,173 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Output drivers of the sinks, they match the audio drivers of the
+// configuration
+const (
+	DriverALSA = "alsa"
+	DriverWAV  = "wav"
+	DriverNull = "null"
+)
+
+// Sink plays interleaved signed 16-bit little endian stereo frames
+type Sink interface {
+	// Open prepares the output for a stream of the sample rate and returns
+	// the rate it plays at, the stream has to be resampled when they differ
+	Open(rate int) (int, error)
+	// Write plays the frames of pcm, it blocks at the pace of the output
+	Write(pcm []byte) error
+	// Drain waits until the written frames have been played
+	Drain() error
+	// Close releases the output, frames which have not been played yet are
+	// dropped
+	Close() error
+}
+
+// FrameSize is the byte size of a stereo frame written to a Sink
+const FrameSize = 4
+
+// NewSink creates the sink of an output driver. The ALSA device is a hw
+// name such as hw:CARD=Device,DEV=0 or hw:1,0, empty for the first playback
+// device, the WAV device is the path of the file
+func NewSink(driver string, device string) (Sink, error) {
+	switch driver {
+	case DriverALSA, "":
+		return newALSASink(device), nil
+	case DriverWAV:
+		if device == "" {
+			return nil, fmt.Errorf("the wav output requires the path of the file as device")
+		}
+		return &wavSink{path: device}, nil
+	case DriverNull:
+		return &nullSink{}, nil
+	}
+	return nil, fmt.Errorf("the %s output is not supported by the native backend, use alsa, wav or null", driver)
+}
+
+// pacer blocks the writes of sinks without a device clock so they play in
+// real time
+type pacer struct {
+	rate    int
+	started time.Time
+	frames  int64
+}
+
+// start resets the clock for a stream of rate
+func (p *pacer) start(rate int) {
+	p.rate = rate
+	p.started = time.Now()
+	p.frames = 0
+}
+
+// wait sleeps until the frames written so far would have been played
+func (p *pacer) wait(frames int) {
+	p.frames += int64(frames)
+	played := time.Duration(p.frames) * time.Second / time.Duration(p.rate)
+	if delay := time.Until(p.started.Add(played)); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// nullSink discards the audio in real time, it is used for tests and for
+// machines without a sound card
+type nullSink struct {
+	pacer
+}
+
+// Open accepts any sample rate
+func (s *nullSink) Open(rate int) (int, error) {
+	s.start(rate)
+	return rate, nil
+}
+
+// Write discards pcm at the pace of the sample rate
+func (s *nullSink) Write(pcm []byte) error {
+	s.wait(len(pcm) / FrameSize)
+	return nil
+}
+
+// Drain returns at once as the writes are paced
+func (s *nullSink) Drain() error {
+	return nil
+}
+
+// Close does nothing
+func (s *nullSink) Close() error {
+	return nil
+}
+
+// wavHeaderSize is the size of the RIFF, fmt and data chunk headers
+const wavHeaderSize = 44
+
+// wavSink records the audio into a WAV file in real time, each stream
+// replaces the file
+type wavSink struct {
+	pacer
+	path string
+	file *os.File
+	size int64
+}
+
+// Open creates the file and reserves its header
+func (s *wavSink) Open(rate int) (int, error) {
+	file, err := os.Create(s.path)
+	if err != nil {
+		return 0, err
+	}
+	s.file, s.size = file, 0
+	s.start(rate)
+	return rate, s.writeHeader()
+}
+
+// writeHeader writes the header of the frames written so far
+func (s *wavSink) writeHeader() error {
+	header := make([]byte, wavHeaderSize)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(wavHeaderSize-8+s.size))
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], wavPCM)
+	binary.LittleEndian.PutUint16(header[22:], 2)
+	binary.LittleEndian.PutUint32(header[24:], uint32(s.rate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(s.rate*FrameSize))
+	binary.LittleEndian.PutUint16(header[32:], FrameSize)
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], uint32(s.size))
+	_, err := s.file.WriteAt(header, 0)
+	return err
+}
+
+// Write appends pcm to the file at the pace of the sample rate
+func (s *wavSink) Write(pcm []byte) error {
+	if _, err := s.file.WriteAt(pcm, wavHeaderSize+s.size); err != nil {
+		return err
+	}
+	s.size += int64(len(pcm))
+	s.wait(len(pcm) / FrameSize)
+	return nil
+}
+
+// Drain returns at once as the writes are paced
+func (s *wavSink) Drain() error {
+	return nil
+}
+
+// Close completes the header with the size of the recording
+func (s *wavSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.writeHeader()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
@@ -0,0 +1,51 @@
+package audio
+
+import (
+	"github.com/jfreymuth/oggvorbis"
+	"io"
+)
+
+// vorbisDecoder decodes Vorbis in an Ogg container
+type vorbisDecoder struct {
+	reader   *oggvorbis.Reader
+	seekable bool
+}
+
+// newVorbis creates a decoder of the Ogg Vorbis stream r, seekable streams
+// are searched for their last page to know the duration
+func newVorbis(r io.Reader) (*vorbisDecoder, error) {
+	reader, err := oggvorbis.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	_, seekable := r.(io.Seeker)
+	return &vorbisDecoder{reader: reader, seekable: seekable}, nil
+}
+
+// Format returns the format of the identification header
+func (d *vorbisDecoder) Format() Format {
+	return Format{Name: "vorbis", SampleRate: d.reader.SampleRate(), Channels: d.reader.Channels()}
+}
+
+// Duration returns the granule position of the last page
+func (d *vorbisDecoder) Duration() float64 {
+	return float64(d.reader.Length()) / float64(d.reader.SampleRate())
+}
+
+// bitRate returns the nominal bit rate of the identification header
+func (d *vorbisDecoder) bitRate() (int, int64) {
+	return d.reader.Bitrate().Nominal, 0
+}
+
+// Read decodes the samples into buf
+func (d *vorbisDecoder) Read(buf []float32) (int, error) {
+	return d.reader.Read(buf)
+}
+
+// Seek moves to the sample at second
+func (d *vorbisDecoder) Seek(second float64) error {
+	if !d.seekable {
+		return discard(d, samplesAt(d.Format(), second))
+	}
+	return d.reader.SetPosition(int64(second * float64(d.reader.SampleRate())))
+}
@@ -0,0 +1,186 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAV sample encodings of the fmt chunk
+const (
+	wavPCM        = 1
+	wavFloat      = 3
+	wavExtensible = 0xfffe
+)
+
+// wavDecoder reads the PCM samples of a RIFF WAVE file
+type wavDecoder struct {
+	r          io.Reader
+	seeker     io.Seeker
+	format     Format
+	encoding   uint16
+	blockAlign int
+	dataStart  int64
+	dataSize   int64 // Bytes of the data chunk, -1 when unknown
+	remaining  int64
+	buf        []byte
+}
+
+// newWAV parses the chunks of r up to the data chunk
+func newWAV(r io.Reader) (*wavDecoder, error) {
+	d := &wavDecoder{r: r}
+	d.seeker, _ = r.(io.Seeker)
+
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, err
+	}
+	offset := int64(len(riff))
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("wav: no data chunk: %w", err)
+		}
+		offset += int64(len(header))
+		id, size := string(header[:4]), int64(binary.LittleEndian.Uint32(header[4:]))
+		if id == "data" {
+			if d.format.SampleRate == 0 {
+				return nil, errors.New("wav: data before fmt chunk")
+			}
+			d.dataStart, d.dataSize, d.remaining = offset, size, size
+			if size == math.MaxUint32 {
+				// Written while streaming, the file is read up to its end
+				d.dataSize, d.remaining = -1, math.MaxInt64
+			}
+			return d, nil
+		}
+
+		// Chunks are padded to an even size
+		chunk := make([]byte, size+size%2)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, err
+		}
+		offset += int64(len(chunk))
+		if id == "fmt " {
+			if err := d.parseFormat(chunk[:size]); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// parseFormat reads the sample encoding of the fmt chunk
+func (d *wavDecoder) parseFormat(chunk []byte) error {
+	if len(chunk) < 16 {
+		return errors.New("wav: short fmt chunk")
+	}
+	d.encoding = binary.LittleEndian.Uint16(chunk[0:])
+	if d.encoding == wavExtensible && len(chunk) >= 26 {
+		// The first two bytes of the sub format GUID are the encoding
+		d.encoding = binary.LittleEndian.Uint16(chunk[24:])
+	}
+	d.format = Format{
+		Name:          "wav",
+		Channels:      int(binary.LittleEndian.Uint16(chunk[2:])),
+		SampleRate:    int(binary.LittleEndian.Uint32(chunk[4:])),
+		BitsPerSample: int(binary.LittleEndian.Uint16(chunk[14:])),
+	}
+	d.blockAlign = int(binary.LittleEndian.Uint16(chunk[12:]))
+
+	bits := d.format.BitsPerSample
+	switch {
+	case d.format.Channels == 0 || d.format.SampleRate == 0:
+		return errors.New("wav: invalid fmt chunk")
+	case d.encoding == wavPCM && (bits == 8 || bits == 16 || bits == 24 || bits == 32):
+	case d.encoding == wavFloat && (bits == 32 || bits == 64):
+	default:
+		return fmt.Errorf("wav: unsupported encoding %d with %d bits", d.encoding, bits)
+	}
+	if d.blockAlign != d.format.Channels*bits/8 {
+		return errors.New("wav: invalid block align")
+	}
+	return nil
+}
+
+// Format returns the format of the fmt chunk
+func (d *wavDecoder) Format() Format {
+	return d.format
+}
+
+// Duration returns the length of the data chunk
+func (d *wavDecoder) Duration() float64 {
+	if d.dataSize < 0 {
+		return 0
+	}
+	return float64(d.dataSize/int64(d.blockAlign)) / float64(d.format.SampleRate)
+}
+
+// Read converts the samples of the data chunk into buf
+func (d *wavDecoder) Read(buf []float32) (int, error) {
+	width := d.format.BitsPerSample / 8
+	size := int64(len(buf) / d.format.Channels * d.blockAlign)
+	if size > d.remaining {
+		size = d.remaining - d.remaining%int64(d.blockAlign)
+	}
+	if size == 0 {
+		return 0, io.EOF
+	}
+	if int64(cap(d.buf)) < size {
+		d.buf = make([]byte, size)
+	}
+	data := d.buf[:size]
+	n, err := io.ReadFull(d.r, data)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	n -= n % d.blockAlign
+	d.remaining -= int64(n)
+
+	samples := n / width
+	for i := 0; i < samples; i++ {
+		buf[i] = d.sample(data[i*width:])
+	}
+	if samples == 0 && err == nil {
+		err = io.EOF
+	}
+	return samples, err
+}
+
+// sample converts the little endian sample at the start of b
+func (d *wavDecoder) sample(b []byte) float32 {
+	switch d.format.BitsPerSample {
+	case 8:
+		// 8-bit samples are unsigned
+		return float32(int(b[0])-128) / 128
+	case 16:
+		return float32(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case 24:
+		return float32(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+	case 32:
+		if d.encoding == wavFloat {
+			return math.Float32frombits(binary.LittleEndian.Uint32(b))
+		}
+		return float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+	return float32(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+}
+
+// Seek moves to the sample frame at second
+func (d *wavDecoder) Seek(second float64) error {
+	if d.seeker == nil {
+		return discard(d, samplesAt(d.format, second))
+	}
+	offset := int64(second*float64(d.format.SampleRate)) * int64(d.blockAlign)
+	if d.dataSize >= 0 && offset > d.dataSize {
+		offset = d.dataSize
+	}
+	if _, err := d.seeker.Seek(d.dataStart+offset, io.SeekStart); err != nil {
+		return err
+	}
+	if d.dataSize >= 0 {
+		d.remaining = d.dataSize - offset
+	}
+	return nil
+}
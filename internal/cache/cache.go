@@ -1,35 +1,115 @@
 package cache
 
 import (
+	"context"
 	"crypto/md5"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"mmfm-playback-go/internal/logger"
 	"mmfm-playback-go/pkg/types"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Cache interface defines the caching functionality
 type Cache interface {
+	// Cache returns the location of a cached key, a miss returns the key
+	// itself and caches it in background
 	Cache(key string) string
+	// Fetch returns the location the backends should play key from
+	Fetch(ctx context.Context, key string) (string, error)
+	// Has checks if key is completely cached
+	Has(key string) bool
+	// Loudness returns the measured loudness of a cached key
+	Loudness(key string) (*Loudness, bool)
+	// Pin protects the entries of keys from eviction
+	Pin(keys ...string)
 	Clean(playlist []*types.Song) error
 	Flush() error
 }
 
 // FileCache implements file-based caching
 type FileCache struct {
-	basePath string
+	keying
+	requesting
+	basePath  string
+	analyzer  *LoudnessAnalyzer
+	analyzing map[string]bool
+	mode      string
+	transfers map[string]*transfer
+	server    *localServer
+	maxSize   int64
+	maxAge    time.Duration
+	pinned    map[string]bool
+	index     map[string]time.Time
+	dedup     bool
+	evictStop chan struct{}
+	lock      sync.Mutex
 }
 
 // NewFileCache creates a new FileCache instance
 func NewFileCache(basePath string) *FileCache {
-	return &FileCache{
-		basePath: basePath,
+	fc := &FileCache{
+		basePath:  basePath,
+		mode:      ModeAsync,
+		transfers: make(map[string]*transfer),
+		analyzing: make(map[string]bool),
 	}
+	fc.server = newLocalServer(fc.Open)
+	return fc
+}
+
+// SetLoudnessAnalyzer enables the loudness analysis of cached files
+func (fc *FileCache) SetLoudnessAnalyzer(analyzer *LoudnessAnalyzer) {
+	fc.analyzer = analyzer
+}
+
+// Loudness returns the measured loudness of a cached file
+func (fc *FileCache) Loudness(key string) (*Loudness, bool) {
+	path := filepath.Join(fc.basePath, "data", fc.generateKey(key))
+	loudness, err := readLoudness(path)
+	if err != nil {
+		return nil, false
+	}
+	return loudness, true
+}
+
+// analyze measures the loudness of a cached file once, the result is kept
+// in a sidecar file next to the cache entry. Concurrent hits of the same
+// entry share the analysis in progress
+func (fc *FileCache) analyze(path string) {
+	if fc.analyzer == nil {
+		return
+	}
+	if _, err := os.Stat(path + loudnessExt); err == nil {
+		return
+	}
+
+	fc.lock.Lock()
+	if fc.analyzing[path] {
+		fc.lock.Unlock()
+		return
+	}
+	fc.analyzing[path] = true
+	fc.lock.Unlock()
+	defer func() {
+		fc.lock.Lock()
+		delete(fc.analyzing, path)
+		fc.lock.Unlock()
+	}()
+
+	loudness, err := fc.analyzer.Analyze(path)
+	if err != nil {
+		logger.Logger.Error("loudness analysis failed:", err)
+		return
+	}
+	if err := writeLoudness(path, loudness); err != nil {
+		logger.Logger.Error(err)
+		return
+	}
+	logger.Logger.Debugf("loudness of %s: %.1f LUFS", path, loudness.Integrated)
 }
 
 // Flush removes all cached files
@@ -51,7 +131,8 @@ func (fc *FileCache) Clean(playlist []*types.Song) error {
 	}
 
 	for _, path := range allCaches {
-		cache := filepath.Base(path)
+		// Sidecar files share the hash of their cache entry
+		cache := strings.SplitN(filepath.Base(path), ".", 2)[0]
 
 		for _, hash := range mapHash {
 			if strings.EqualFold(hash, cache) {
@@ -65,80 +146,34 @@ func (fc *FileCache) Clean(playlist []*types.Song) error {
 	return nil
 }
 
-// generateKey generates a unique key for a URL
+// generateKey generates a unique key for a URL following the key strategy
 func (fc *FileCache) generateKey(key string) string {
+	return fc.hash(key)
+}
+
+// hashKey generates the storage name of a key
+func hashKey(key string) string {
 	hash := md5.Sum([]byte(key))
 	return fmt.Sprintf("%x", hash)
 }
 
-// Cache caches a file from a URL if not already cached
+// Has checks if key is completely cached
+func (fc *FileCache) Has(key string) bool {
+	return isComplete(filepath.Join(fc.basePath, "data", fc.generateKey(key)))
+}
+
+// Cache caches a file from a URL if not already cached, a miss returns the
+// URL itself while the file is downloaded in background
 func (fc *FileCache) Cache(key string) string {
 	hashKey := fc.generateKey(key)
-
 	path := filepath.Join(fc.basePath, "data", hashKey)
-	if _, err := os.Stat(path); err == nil {
+	if isComplete(path) {
 		logger.Logger.Info("cache hint ", path)
+		fc.touch(hashKey)
+		go fc.analyze(path)
 		return path
 	}
 
-	go func() {
-		logger.Logger.Debug("begin cache music file")
-
-		path := filepath.Join(fc.basePath, "data", hashKey)
-		dir := filepath.Dir(path)
-
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			os.MkdirAll(dir, 0777)
-		}
-
-		isHTTP := strings.HasPrefix(key, "http")
-
-		if isHTTP {
-			resp, err := http.Get(key)
-			if err != nil {
-				logger.Logger.Error(err)
-				return
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != 200 {
-				logger.Logger.Error("url return:", resp.StatusCode)
-				return
-			}
-
-			out, err := os.Create(path)
-			if err != nil {
-				logger.Logger.Error(err)
-				return
-			}
-			defer out.Close()
-			// Write the body to file
-			_, err = io.Copy(out, resp.Body)
-			if err != nil {
-				logger.Logger.Error(err)
-				return
-			}
-		} else {
-			content, err := ioutil.ReadFile(key)
-			if err != nil {
-				logger.Logger.Error(err)
-				return
-			}
-
-			out, err := os.Create(path)
-			if err != nil {
-				logger.Logger.Error(err)
-				return
-			}
-			defer out.Close()
-			_, err = out.Write(content)
-			if err != nil {
-				logger.Logger.Error(err)
-			}
-		}
-
-		logger.Logger.Debug("cache music file:", path)
-	}()
-
+	fc.start(key)
 	return key
 }
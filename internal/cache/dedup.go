@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"mmfm-playback-go/internal/logger"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetDedup stores identical content downloaded under different keys once
+func (fc *FileCache) SetDedup(enabled bool) {
+	fc.dedup = enabled
+}
+
+// dedupe replaces the entry at path by a hard link to an older entry with the
+// same content, the entry is kept as is if the filesystem has no hard links
+func (fc *FileCache) dedupe(path string) {
+	meta, err := readMeta(path)
+	if err != nil {
+		return
+	}
+
+	metas, err := filepath.Glob(filepath.Join(fc.basePath, "data", "*"+metaExt))
+	if err != nil {
+		return
+	}
+	for _, metaPath := range metas {
+		other := strings.TrimSuffix(metaPath, metaExt)
+		if other == path {
+			continue
+		}
+		otherMeta, err := readMeta(other)
+		if err != nil || otherMeta.SHA256 != meta.SHA256 || !isComplete(other) {
+			continue
+		}
+
+		link := path + ".link"
+		os.Remove(link)
+		if err := os.Link(other, link); err != nil {
+			logger.Logger.Debug("cannot deduplicate cache entry:", err)
+			return
+		}
+		if err := os.Rename(link, path); err != nil {
+			os.Remove(link)
+			logger.Logger.Error(err)
+			return
+		}
+		logger.Logger.Infof("cache entry %s shares the content of %s", filepath.Base(path), filepath.Base(other))
+		return
+	}
+}
@@ -0,0 +1,322 @@
+package cache
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/httpclient"
+	"mmfm-playback-go/internal/logger"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// partExt is the extension of a download in progress
+	partExt = ".part"
+	// metaExt is the extension of the sidecar file describing a cache entry
+	metaExt = ".meta"
+	// maxAliases bounds the other URLs remembered by an entry
+	maxAliases = 16
+)
+
+// errCached stops a download whose ETag belongs to a cached entry
+var errCached = errors.New("content already cached")
+
+// requesting gives a cache a configurable HTTP client
+type requesting struct {
+	client *httpclient.Client
+}
+
+// SetClient selects the HTTP client of the downloads
+func (rq *requesting) SetClient(client *httpclient.Client) {
+	rq.client = client
+}
+
+// httpClient returns the selected HTTP client or the default one
+func (rq *requesting) httpClient() *httpclient.Client {
+	if rq.client == nil {
+		return httpclient.Default
+	}
+	return rq.client
+}
+
+// Meta describes where and when a cache entry was fetched
+type Meta struct {
+	URL          string    `json:"url"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	MD5          string    `json:"md5"`
+	SHA256       string    `json:"sha256"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	// Aliases are other URLs which answered with the ETag of the entry
+	Aliases []string `json:"aliases,omitempty"`
+	// Partial is set while only the temporary file of an interrupted
+	// download exists, the validators identify the content it holds
+	Partial bool `json:"partial,omitempty"`
+}
+
+// readMeta reads the sidecar file of a cache entry
+func readMeta(path string) (*Meta, error) {
+	content, err := os.ReadFile(path + metaExt)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Meta{}
+	if err := json.Unmarshal(content, meta); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// writeMeta stores the sidecar file next to the cache entry
+func writeMeta(path string, meta *Meta) error {
+	content, err := json.MarshalIndent(meta, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path+metaExt, content, 0644)
+}
+
+// isComplete checks that the entry at path has been fully downloaded, entries
+// without metadata predate verified downloads and are not trusted
+func isComplete(path string) bool {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	meta, err := readMeta(path)
+	if err != nil {
+		return false
+	}
+	return !meta.Partial && meta.Size == stat.Size()
+}
+
+// download fetches key into path, the content is written to a temporary
+// file which is only renamed to path once it has been verified. ready is
+// called with the expected size (-1 if unknown) once the temporary file
+// starts to receive content, wrap may decorate the source reader. A known
+// ETag stops the download with errCached before the content is received.
+// Interrupted downloads from servers accepting ranges keep their temporary
+// file and are resumed by the next download of the same key, as long as the
+// content did not change in between
+func download(client *httpclient.Client, key string, path string, ready func(size int64), wrap func(io.Reader) io.Reader, known func(etag string) bool) error {
+	part := path + partExt
+	meta := &Meta{
+		URL: key,
+	}
+	if previous, err := readMeta(path); err == nil && previous.Partial && previous.URL == key {
+		meta.ETag, meta.LastModified = previous.ETag, previous.LastModified
+	}
+	// A stale sidecar must not vouch for the new content
+	os.Remove(path + metaExt)
+
+	out, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	resumable := false
+	if strings.HasPrefix(key, "http") {
+		resumable, err = fetchHTTP(client, key, out, meta, ready, wrap, known)
+	} else {
+		err = copyFile(key, out, meta, ready, wrap)
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	out.Close()
+	if err != nil {
+		if !resumable {
+			os.Remove(part)
+		} else if meta.ETag != "" || meta.LastModified != "" {
+			meta.Partial = true
+			writeMeta(path, meta)
+		}
+		return err
+	}
+
+	if err := os.Rename(part, path); err != nil {
+		os.Remove(part)
+		return err
+	}
+	meta.FetchedAt = time.Now()
+	return writeMeta(path, meta)
+}
+
+// fetchHTTP downloads url into out and verifies its length and checksum, a
+// partial content already in out is resumed with a range request which is
+// conditional on the validators of meta, a changed content is downloaded
+// again from the start. The returned flag tells whether a failed download
+// may be resumed later
+func fetchHTTP(client *httpclient.Client, url string, out *os.File, meta *Meta, ready func(size int64), wrap func(io.Reader) io.Reader, known func(etag string) bool) (bool, error) {
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 {
+		// Weak ETags can not guard a range
+		validator := meta.ETag
+		if validator == "" || strings.HasPrefix(validator, "W/") {
+			validator = meta.LastModified
+		}
+		if validator != "" {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", validator)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return offset > 0, err
+	}
+	defer resp.Body.Close()
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Hash the content received before
+		if _, err := out.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), out); err != nil {
+			return false, err
+		}
+		logger.Logger.Debugf("resume download of %s at %d bytes", url, offset)
+	case http.StatusOK:
+		offset = 0
+		if err := out.Truncate(0); err != nil {
+			return false, err
+		}
+		if _, err := out.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+	default:
+		return false, fmt.Errorf("url return: %d", resp.StatusCode)
+	}
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	resumable := resp.Header.Get("Accept-Ranges") == "bytes" || resp.StatusCode == http.StatusPartialContent
+	meta.ETag = resp.Header.Get("ETag")
+	meta.LastModified = resp.Header.Get("Last-Modified")
+	if resp.StatusCode == http.StatusOK && meta.ETag != "" && known != nil && known(meta.ETag) {
+		return false, errCached
+	}
+	ready(total)
+
+	size, err := io.Copy(io.MultiWriter(out, md5Hash, sha256Hash), wrap(resp.Body))
+	if err != nil {
+		return resumable, err
+	}
+	meta.Size = offset + size
+	meta.MD5 = hex.EncodeToString(md5Hash.Sum(nil))
+	meta.SHA256 = hex.EncodeToString(sha256Hash.Sum(nil))
+
+	if total >= 0 && meta.Size != total {
+		return resumable, fmt.Errorf("truncated download of %s: got %d of %d bytes", url, meta.Size, total)
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		// Checksum headers of a range response only cover the range
+		return false, nil
+	}
+	return false, verifyChecksum(resp.Header, meta)
+}
+
+// copyFile copies a local file into out
+func copyFile(source string, out *os.File, meta *Meta, ready func(size int64), wrap func(io.Reader) io.Reader) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := out.Truncate(0); err != nil {
+		return err
+	}
+	ready(stat.Size())
+	return writeVerified(wrap(in), out, meta)
+}
+
+// writeVerified copies reader into out and records its size and checksums
+func writeVerified(reader io.Reader, out io.Writer, meta *Meta) error {
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, md5Hash, sha256Hash), reader)
+	if err != nil {
+		return err
+	}
+
+	meta.Size = size
+	meta.MD5 = hex.EncodeToString(md5Hash.Sum(nil))
+	meta.SHA256 = hex.EncodeToString(sha256Hash.Sum(nil))
+	return nil
+}
+
+// verifyChecksum checks the download against the Content-MD5 or Digest
+// headers of the response, if the server sent any
+func verifyChecksum(header http.Header, meta *Meta) error {
+	if value := header.Get("Content-MD5"); value != "" {
+		return compareChecksum(value, meta.MD5)
+	}
+	for _, digest := range strings.Split(header.Get("Digest"), ",") {
+		algorithm, value, found := strings.Cut(strings.TrimSpace(digest), "=")
+		if found && strings.EqualFold(algorithm, "sha-256") {
+			return compareChecksum(value, meta.SHA256)
+		}
+	}
+	return nil
+}
+
+// compareChecksum compares a base64 encoded checksum with a hex encoded one
+func compareChecksum(expected string, actual string) error {
+	decoded, err := base64.StdEncoding.DecodeString(expected)
+	if err != nil {
+		return fmt.Errorf("invalid checksum header %q: %w", expected, err)
+	}
+	if hex.EncodeToString(decoded) != actual {
+		return fmt.Errorf("checksum mismatch: expected %x, got %s", decoded, actual)
+	}
+	return nil
+}
+
+// openSource opens the original content of key, a URL or a local file, with
+// its size or -1 if unknown
+func openSource(ctx context.Context, client *httpclient.Client, key string) (io.ReadCloser, int64, error) {
+	if !strings.HasPrefix(key, "http") {
+		return openFile(key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("url return: %d", resp.StatusCode)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"encoding/json"
+	"mmfm-playback-go/internal/logger"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// indexFile is the name of the access time index inside the base path
+const indexFile = "index.json"
+
+// entry is a cache entry considered for eviction
+type entry struct {
+	hash       string
+	size       int64
+	accessedAt time.Time
+}
+
+// SetLimits bounds the cache by total size in bytes and by the age of the
+// last access, zero disables a limit
+func (fc *FileCache) SetLimits(maxSize int64, maxAge time.Duration) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.maxSize = maxSize
+	fc.maxAge = maxAge
+}
+
+// Pin protects the entries of keys from eviction, it replaces the keys
+// pinned before
+func (fc *FileCache) Pin(keys ...string) {
+	pinned := make(map[string]bool)
+	for _, key := range keys {
+		pinned[fc.generateKey(key)] = true
+	}
+
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.pinned = pinned
+}
+
+// StartEviction enforces the limits in background every interval, it
+// replaces an eviction started before
+func (fc *FileCache) StartEviction(interval time.Duration) {
+	stop := make(chan struct{})
+	fc.lock.Lock()
+	if fc.evictStop != nil {
+		close(fc.evictStop)
+	}
+	fc.evictStop = stop
+	fc.lock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := fc.Evict(); err != nil {
+				logger.Logger.Error(err)
+			}
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopEviction stops the background eviction
+func (fc *FileCache) StopEviction() {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	if fc.evictStop != nil {
+		close(fc.evictStop)
+		fc.evictStop = nil
+	}
+}
+
+// touch records an access of the entry
+func (fc *FileCache) touch(hashKey string) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.loadIndex()
+	fc.index[hashKey] = time.Now()
+	fc.saveIndex()
+}
+
+// loadIndex reads the access time index once, the lock must be held
+func (fc *FileCache) loadIndex() {
+	if fc.index != nil {
+		return
+	}
+	fc.index = make(map[string]time.Time)
+
+	content, err := os.ReadFile(filepath.Join(fc.basePath, indexFile))
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(content, &fc.index); err != nil {
+		logger.Logger.Error(err)
+	}
+}
+
+// saveIndex writes the access time index, the lock must be held
+func (fc *FileCache) saveIndex() {
+	content, err := json.Marshal(fc.index)
+	if err != nil {
+		logger.Logger.Error(err)
+		return
+	}
+	os.MkdirAll(fc.basePath, 0777)
+	if err := os.WriteFile(filepath.Join(fc.basePath, indexFile), content, 0644); err != nil {
+		logger.Logger.Error(err)
+	}
+}
+
+// entries lists the complete cache entries with their size including the
+// sidecar files, the lock must be held
+func (fc *FileCache) entries() ([]*entry, error) {
+	paths, err := filepath.Glob(filepath.Join(fc.basePath, "data", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*entry)
+	for _, path := range paths {
+		stat, err := os.Stat(path)
+		if err != nil || stat.IsDir() {
+			continue
+		}
+		name := filepath.Base(path)
+		if strings.HasSuffix(name, partExt) {
+			continue
+		}
+		hash := strings.SplitN(name, ".", 2)[0]
+
+		e, ok := entries[hash]
+		if !ok {
+			e = &entry{hash: hash}
+			entries[hash] = e
+		}
+		e.size += stat.Size()
+		if name == hash {
+			e.accessedAt = stat.ModTime()
+		}
+	}
+
+	list := make([]*entry, 0, len(entries))
+	for hash, e := range entries {
+		if accessedAt, ok := fc.index[hash]; ok {
+			e.accessedAt = accessedAt
+		}
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].accessedAt.Before(list[j].accessedAt)
+	})
+	return list, nil
+}
+
+// Evict removes the least recently used entries until the cache fits its
+// limits, pinned entries and downloads in progress are never evicted
+func (fc *FileCache) Evict() error {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	if fc.maxSize <= 0 && fc.maxAge <= 0 {
+		return nil
+	}
+
+	fc.loadIndex()
+	entries, err := fc.entries()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		if fc.pinned[e.hash] || fc.transfers[e.hash] != nil {
+			continue
+		}
+		expired := fc.maxAge > 0 && now.Sub(e.accessedAt) > fc.maxAge
+		oversized := fc.maxSize > 0 && total > fc.maxSize
+		if !expired && !oversized {
+			continue
+		}
+
+		logger.Logger.Info("evict cache entry", e.hash)
+		fc.removeEntry(e.hash)
+		total -= e.size
+	}
+	fc.saveIndex()
+
+	return nil
+}
+
+// removeEntry deletes a cache entry with its sidecar files, the lock must
+// be held
+func (fc *FileCache) removeEntry(hashKey string) {
+	path := filepath.Join(fc.basePath, "data", hashKey)
+	for _, ext := range []string{"", metaExt, loudnessExt} {
+		os.Remove(path + ext)
+	}
+	delete(fc.index, hashKey)
+}
+
+// EntryInfo describes a cache entry of a listing
+type EntryInfo struct {
+	Hash       string    `json:"hash"`
+	URL        string    `json:"url,omitempty"` // From the meta sidecar, empty for entries cached before it existed
+	Size       int64     `json:"size"`          // Including the sidecar files
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+// List returns the complete cache entries, the least recently used first
+func (fc *FileCache) List() ([]EntryInfo, error) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.loadIndex()
+	entries, err := fc.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]EntryInfo, 0, len(entries))
+	for _, e := range entries {
+		info := EntryInfo{Hash: e.hash, Size: e.size, AccessedAt: e.accessedAt}
+		if meta, err := readMeta(filepath.Join(fc.basePath, "data", e.hash)); err == nil {
+			info.URL = meta.URL
+		}
+		list = append(list, info)
+	}
+	return list, nil
+}
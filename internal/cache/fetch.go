@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"mmfm-playback-go/internal/logger"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache modes
+const (
+	// ModeAsync plays the original URL on a miss and caches in background
+	ModeAsync = "async"
+	// ModeSync waits for the download before playing from the cache
+	ModeSync = "sync"
+	// ModeStream plays the download while it is in progress through a
+	// local HTTP endpoint
+	ModeStream = "stream"
+)
+
+// pollInterval is how often a stream reader checks for new content
+const pollInterval = 100 * time.Millisecond
+
+// transfer is a download in progress, it is shared by every caller asking
+// for the same key
+type transfer struct {
+	key   string
+	path  string
+	ready chan struct{}
+	done  chan struct{}
+	size  int64
+	err   error
+	once  sync.Once
+	// throttled is set while only the prefetcher wants the transfer
+	throttled atomic.Bool
+}
+
+// markReady records the expected size and releases the waiting readers
+func (t *transfer) markReady(size int64) {
+	t.once.Do(func() {
+		t.size = size
+		close(t.ready)
+	})
+}
+
+// start returns the transfer of key for playback, a prefetch of the same key
+// in progress is no longer throttled
+func (fc *FileCache) start(key string) *transfer {
+	return fc.startTransfer(key, false, nil, nil)
+}
+
+// startTransfer returns the transfer of key, a new download is only started
+// when none is in progress. Throttled transfers are limited by limiter and
+// report the bytes received to counter
+func (fc *FileCache) startTransfer(key string, throttled bool, limiter *rateLimiter, counter func(n int)) *transfer {
+	hashKey := fc.generateKey(key)
+
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	if t, ok := fc.transfers[hashKey]; ok {
+		if !throttled {
+			t.throttled.Store(false)
+		}
+		return t
+	}
+
+	t := &transfer{
+		key:   key,
+		path:  filepath.Join(fc.basePath, "data", hashKey),
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+		size:  -1,
+	}
+	if isComplete(t.path) {
+		// A transfer finished since the caller checked the entry, the
+		// download would remove the metadata it just wrote
+		t.markReady(-1)
+		close(t.done)
+		return t
+	}
+	t.throttled.Store(throttled)
+	fc.transfers[hashKey] = t
+
+	wrap := func(reader io.Reader) io.Reader {
+		if counter == nil {
+			return reader
+		}
+		return &throttledReader{reader: reader, limiter: limiter, transfer: t, counter: counter}
+	}
+
+	// The ETag strategy stops downloads of content which is cached under
+	// another URL
+	var known func(etag string) bool
+	cached := ""
+	if fc.keyer != nil {
+		known = func(etag string) bool {
+			hash, ok := fc.keyer.storedETag(etag)
+			if !ok || hash == hashKey || !isComplete(filepath.Join(fc.basePath, "data", hash)) {
+				return false
+			}
+			cached = hash
+			return true
+		}
+	}
+
+	go func() {
+		logger.Logger.Debug("begin cache music file")
+		os.MkdirAll(filepath.Dir(t.path), 0777)
+
+		entry := hashKey
+		t.err = download(fc.httpClient(), key, t.path, t.markReady, wrap, known)
+		if t.err == errCached {
+			entry = cached
+			t.path = filepath.Join(fc.basePath, "data", entry)
+			t.err = fc.alias(t.path, key)
+		} else if t.err == nil {
+			fc.learn(t.path, key)
+			if fc.dedup {
+				fc.dedupe(t.path)
+			}
+		}
+		t.markReady(-1)
+		if t.err == nil {
+			fc.touch(entry)
+		}
+
+		fc.lock.Lock()
+		delete(fc.transfers, hashKey)
+		fc.lock.Unlock()
+		close(t.done)
+
+		if t.err != nil {
+			logger.Logger.Error(t.err)
+			return
+		}
+		logger.Logger.Debug("cache music file:", t.path)
+		fc.analyze(t.path)
+	}()
+
+	return t
+}
+
+// SetMode selects how Fetch deals with cache misses
+func (fc *FileCache) SetMode(mode string) {
+	fc.mode = mode
+}
+
+// Fetch returns the location the backends should play key from. Depending
+// on the mode a miss returns the original URL, waits for the download or
+// returns a local URL streaming the download in progress
+func (fc *FileCache) Fetch(ctx context.Context, key string) (string, error) {
+	hashKey := fc.generateKey(key)
+	path := filepath.Join(fc.basePath, "data", hashKey)
+	if isComplete(path) {
+		logger.Logger.Info("cache hint ", path)
+		fc.touch(hashKey)
+		go fc.analyze(path)
+		return path, nil
+	}
+
+	t := fc.start(key)
+	switch fc.mode {
+	case ModeSync:
+		select {
+		case <-t.done:
+			if t.err != nil {
+				return "", t.err
+			}
+			return t.path, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	case ModeStream:
+		return fc.server.url(key)
+	}
+	return key, nil
+}
+
+// Open returns a reader of key, a download in progress is followed until it
+// completes
+func (fc *FileCache) Open(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	path := filepath.Join(fc.basePath, "data", fc.generateKey(key))
+	if isComplete(path) {
+		return openFile(path)
+	}
+
+	t := fc.start(key)
+	select {
+	case <-t.ready:
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+
+	file, err := os.Open(t.path + partExt)
+	if os.IsNotExist(err) {
+		// The download finished before it could be followed
+		<-t.done
+		if t.err != nil {
+			return nil, 0, t.err
+		}
+		return openFile(t.path)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &streamReader{ctx: ctx, file: file, transfer: t}, t.size, nil
+}
+
+// openFile opens a complete cache entry
+func openFile(path string) (io.ReadCloser, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, stat.Size(), nil
+}
+
+// streamReader reads a file which is still being downloaded, reaching the
+// end of the file waits for more content until the download is done
+type streamReader struct {
+	ctx      context.Context
+	file     *os.File
+	transfer *transfer
+}
+
+// Read implements io.Reader
+func (sr *streamReader) Read(p []byte) (int, error) {
+	for {
+		n, err := sr.file.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+
+		select {
+		case <-sr.transfer.done:
+			if sr.transfer.err != nil {
+				return 0, sr.transfer.err
+			}
+			// Content written before the download finished
+			return sr.file.Read(p)
+		case <-sr.ctx.Done():
+			return 0, sr.ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Close implements io.Closer
+func (sr *streamReader) Close() error {
+	return sr.file.Close()
+}
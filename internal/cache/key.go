@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"mmfm-playback-go/internal/logger"
+	"mmfm-playback-go/pkg/types"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Key strategies
+const (
+	// KeyURL identifies an entry by its full URL
+	KeyURL = "url"
+	// KeyQuery identifies an entry by its URL without the ignored query
+	// parameters, e.g. the tokens of signed URLs
+	KeyQuery = "query"
+	// KeyID identifies an entry by the song ID of the playlist
+	KeyID = "id"
+	// KeyETag identifies an entry by the ETag its download returned, a URL
+	// answering with the ETag of a cached entry shares that entry
+	KeyETag = "etag"
+)
+
+// maxStored bounds the remembered locations, signed URLs never repeat
+const maxStored = 4096
+
+// Keyer maps the location of a song to the identity of its cache entry, so
+// URLs which change over time keep hitting the same entry
+type Keyer struct {
+	strategy string
+	params   map[string]bool
+	ids      map[string]string
+	// stored and etags index the entries of the ETag strategy by location
+	// and by ETag, the file cache fills them from the metadata of its
+	// entries and from its downloads
+	stored map[string]string
+	etags  map[string]string
+	lock   sync.Mutex
+}
+
+// NewKeyer creates a new Keyer, params lists the query parameters ignored by
+// KeyQuery, none means the whole query is ignored
+func NewKeyer(strategy string, params ...string) *Keyer {
+	k := &Keyer{
+		strategy: strategy,
+		params:   make(map[string]bool),
+		ids:      make(map[string]string),
+		stored:   make(map[string]string),
+		etags:    make(map[string]string),
+	}
+	for _, param := range params {
+		k.params[param] = true
+	}
+	return k
+}
+
+// Register records the song IDs of the locations, it replaces the songs
+// registered before
+func (k *Keyer) Register(songs ...*types.Song) {
+	ids := make(map[string]string)
+	for _, song := range songs {
+		if song == nil {
+			continue
+		}
+		if id := song.GetID(); id != "" {
+			ids[song.GetURL()] = id
+		}
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.ids = ids
+}
+
+// Identity returns the identity of key, it falls back to key itself when
+// the strategy does not apply
+func (k *Keyer) Identity(key string) string {
+	switch k.strategy {
+	case KeyQuery:
+		return k.stripQuery(key)
+	case KeyID:
+		k.lock.Lock()
+		id, ok := k.ids[key]
+		k.lock.Unlock()
+		if ok {
+			return "id:" + id
+		}
+	}
+	return key
+}
+
+// stripQuery removes the ignored query parameters of key
+func (k *Keyer) stripQuery(key string) string {
+	u, err := url.Parse(key)
+	if err != nil || u.RawQuery == "" {
+		return key
+	}
+	if len(k.params) == 0 {
+		u.RawQuery = ""
+		return u.String()
+	}
+
+	query := u.Query()
+	for param := range k.params {
+		query.Del(param)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// learn records that the content of key answering with etag is stored in
+// the entry hash, only the ETag strategy keeps them
+func (k *Keyer) learn(key string, etag string, hash string) {
+	if k.strategy != KeyETag || etag == "" {
+		return
+	}
+	// Weak and strong validators of the same content share an entry
+	etag = strings.TrimPrefix(etag, "W/")
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if len(k.stored) >= maxStored {
+		k.stored = make(map[string]string)
+	}
+	k.stored[key] = hash
+	k.etags[etag] = hash
+}
+
+// storedKey returns the entry learned for key
+func (k *Keyer) storedKey(key string) (string, bool) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	hash, ok := k.stored[key]
+	return hash, ok
+}
+
+// storedETag returns the entry learned for etag
+func (k *Keyer) storedETag(etag string) (string, bool) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	hash, ok := k.etags[strings.TrimPrefix(etag, "W/")]
+	return hash, ok
+}
+
+// keying gives a cache a configurable key strategy
+type keying struct {
+	keyer *Keyer
+}
+
+// SetKeyer selects how keys are mapped to cache entries
+func (kg *keying) SetKeyer(keyer *Keyer) {
+	kg.keyer = keyer
+}
+
+// hash returns the storage name of key
+func (kg *keying) hash(key string) string {
+	if kg.keyer != nil {
+		if hash, ok := kg.keyer.storedKey(key); ok {
+			return hash
+		}
+		key = kg.keyer.Identity(key)
+	}
+	return hashKey(key)
+}
+
+// SetKeyer selects how keys are mapped to cache entries, the ETag strategy
+// learns the URLs and ETags of the entries from their metadata so cached
+// songs are found without asking the upstream server, e.g. while offline
+func (fc *FileCache) SetKeyer(keyer *Keyer) {
+	fc.keying.SetKeyer(keyer)
+
+	metas, err := filepath.Glob(filepath.Join(fc.basePath, "data", "*"+metaExt))
+	if err != nil {
+		return
+	}
+	for _, metaPath := range metas {
+		path := strings.TrimSuffix(metaPath, metaExt)
+		meta, err := readMeta(path)
+		if err != nil || meta.Partial {
+			continue
+		}
+		for _, key := range append([]string{meta.URL}, meta.Aliases...) {
+			keyer.learn(key, meta.ETag, filepath.Base(path))
+		}
+	}
+}
+
+// learn records the ETag of the entry just downloaded at path for key
+func (fc *FileCache) learn(path string, key string) {
+	if fc.keyer == nil {
+		return
+	}
+	if meta, err := readMeta(path); err == nil {
+		fc.keyer.learn(key, meta.ETag, filepath.Base(path))
+	}
+}
+
+// alias makes key share the entry at path, whose content answered with the
+// same ETag. The key is kept in the metadata of the entry for the next run
+func (fc *FileCache) alias(path string, key string) error {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	meta, err := readMeta(path)
+	if err != nil {
+		return err
+	}
+	fc.keyer.learn(key, meta.ETag, filepath.Base(path))
+	if key == meta.URL {
+		return nil
+	}
+
+	aliases := []string{key}
+	for _, alias := range meta.Aliases {
+		if alias != key && len(aliases) < maxAliases {
+			aliases = append(aliases, alias)
+		}
+	}
+	meta.Aliases = aliases
+	logger.Logger.Infof("%s shares the cache entry %s by ETag", key, filepath.Base(path))
+	return writeMeta(path, meta)
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	// loudnessExt is the extension of the sidecar file holding the analysis
+	loudnessExt = ".loudness"
+	// silenceFloor replaces the -inf reported for silent files, it is the
+	// absolute gate of EBU R128
+	silenceFloor = -70
+	// maxBoost limits the gain applied to quiet files
+	maxBoost = 12
+)
+
+// Loudness holds the EBU R128 measurement of an audio file
+type Loudness struct {
+	Integrated float64 `json:"integrated"` // Integrated loudness in LUFS
+	TruePeak   float64 `json:"true_peak"`  // True peak in dBTP
+	Range      float64 `json:"range"`      // Loudness range in LU
+}
+
+// Gain returns the gain in dB needed to reach target LUFS, the gain is
+// limited so the true peak stays below -1 dBTP
+func (l *Loudness) Gain(target float64) float64 {
+	gain := math.Min(target-l.Integrated, maxBoost)
+	if headroom := -1 - l.TruePeak; gain > headroom {
+		gain = headroom
+	}
+	return gain
+}
+
+// LoudnessAnalyzer measures audio files with the ffmpeg loudnorm filter
+type LoudnessAnalyzer struct {
+	bin string
+}
+
+// NewLoudnessAnalyzer creates a new LoudnessAnalyzer instance
+func NewLoudnessAnalyzer(bin string) *LoudnessAnalyzer {
+	return &LoudnessAnalyzer{
+		bin: bin,
+	}
+}
+
+// Analyze runs a loudnorm measurement pass over the file at path
+func (la *LoudnessAnalyzer) Analyze(path string) (*Loudness, error) {
+	cmd := exec.Command(la.bin, "-hide_banner", "-nostats", "-i", path,
+		"-af", "loudnorm=print_format=json", "-f", "null", "-")
+	// loudnorm prints its report on stderr
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLoudnorm(string(output))
+}
+
+// parseLoudnorm extracts the measurement from the loudnorm json report
+func parseLoudnorm(output string) (*Loudness, error) {
+	start := strings.LastIndex(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("loudnorm report not found in output")
+	}
+
+	var report map[string]string
+	if err := json.Unmarshal([]byte(output[start:end+1]), &report); err != nil {
+		return nil, err
+	}
+
+	loudness := &Loudness{}
+	fields := map[string]*float64{
+		"input_i":   &loudness.Integrated,
+		"input_tp":  &loudness.TruePeak,
+		"input_lra": &loudness.Range,
+	}
+	for name, field := range fields {
+		value, err := strconv.ParseFloat(report[name], 64)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsInf(value, 0) || math.IsNaN(value) {
+			value = silenceFloor
+		}
+		*field = value
+	}
+
+	return loudness, nil
+}
+
+// readLoudness reads the sidecar file of a cache entry
+func readLoudness(path string) (*Loudness, error) {
+	content, err := os.ReadFile(path + loudnessExt)
+	if err != nil {
+		return nil, err
+	}
+
+	loudness := &Loudness{}
+	if err := json.Unmarshal(content, loudness); err != nil {
+		return nil, err
+	}
+
+	return loudness, nil
+}
+
+// writeLoudness stores the measurement next to the cache entry
+func writeLoudness(path string, loudness *Loudness) error {
+	content, err := json.Marshal(loudness)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path+loudnessExt, content, 0644)
+}
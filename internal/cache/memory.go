@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mmfm-playback-go/internal/logger"
+	"mmfm-playback-go/pkg/types"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryEntry is a song held by a MemoryCache
+type memoryEntry struct {
+	data       []byte
+	accessedAt time.Time
+}
+
+// memoryLoad is a download in progress into a MemoryCache
+type memoryLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// MemoryCache keeps songs in memory and serves them to the backends over a
+// local HTTP endpoint, it suits tests and devices without writable storage
+type MemoryCache struct {
+	keying
+	requesting
+	maxSize int64
+	size    int64
+	entries map[string]*memoryEntry
+	loads   map[string]*memoryLoad
+	pinned  map[string]bool
+	server  *localServer
+	lock    sync.Mutex
+}
+
+// NewMemoryCache creates a new MemoryCache instance, a maxSize of zero does
+// not limit the memory used
+func NewMemoryCache(maxSize int64) *MemoryCache {
+	mc := &MemoryCache{
+		maxSize: maxSize,
+		entries: make(map[string]*memoryEntry),
+		loads:   make(map[string]*memoryLoad),
+		pinned:  make(map[string]bool),
+	}
+	mc.server = newLocalServer(mc.Open)
+	return mc
+}
+
+// Cache returns the local URL of a cached key, a miss returns the key itself
+// while it is downloaded in background
+func (mc *MemoryCache) Cache(key string) string {
+	if mc.Has(key) {
+		if url, err := mc.server.url(key); err == nil {
+			return url
+		}
+		return key
+	}
+
+	mc.load(key)
+	return key
+}
+
+// Fetch waits for key to be cached and returns its local URL
+func (mc *MemoryCache) Fetch(ctx context.Context, key string) (string, error) {
+	load := mc.load(key)
+	select {
+	case <-load.done:
+		if load.err != nil {
+			return "", load.err
+		}
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	return mc.server.url(key)
+}
+
+// Has checks if key is cached
+func (mc *MemoryCache) Has(key string) bool {
+	hash := mc.hash(key)
+
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	_, ok := mc.entries[hash]
+	return ok
+}
+
+// Loudness is not measured by a MemoryCache
+func (mc *MemoryCache) Loudness(key string) (*Loudness, bool) {
+	return nil, false
+}
+
+// Pin protects the entries of keys from eviction, it replaces the keys
+// pinned before
+func (mc *MemoryCache) Pin(keys ...string) {
+	pinned := make(map[string]bool)
+	for _, key := range keys {
+		pinned[mc.hash(key)] = true
+	}
+
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	mc.pinned = pinned
+}
+
+// Clean removes the songs that are not in the playlist
+func (mc *MemoryCache) Clean(playlist []*types.Song) error {
+	keep := make(map[string]bool)
+	for _, song := range playlist {
+		keep[mc.hash(song.GetURL())] = true
+	}
+
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	for hash := range mc.entries {
+		if !keep[hash] {
+			mc.remove(hash)
+		}
+	}
+	return nil
+}
+
+// Flush removes all songs
+func (mc *MemoryCache) Flush() error {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	mc.entries = make(map[string]*memoryEntry)
+	mc.size = 0
+	return nil
+}
+
+// Open returns a reader of a cached key, a miss is downloaded first
+func (mc *MemoryCache) Open(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	load := mc.load(key)
+	select {
+	case <-load.done:
+		if load.err != nil {
+			return nil, 0, load.err
+		}
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+
+	hash := mc.hash(key)
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	e, ok := mc.entries[hash]
+	if !ok {
+		// Evicted right after its download
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	e.accessedAt = time.Now()
+	return io.NopCloser(bytes.NewReader(e.data)), int64(len(e.data)), nil
+}
+
+// load returns the download of key, a cached key returns a finished one and
+// a download in progress is shared
+func (mc *MemoryCache) load(key string) *memoryLoad {
+	hash := mc.hash(key)
+
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	if e, ok := mc.entries[hash]; ok {
+		e.accessedAt = time.Now()
+		load := &memoryLoad{done: make(chan struct{})}
+		close(load.done)
+		return load
+	}
+	if load, ok := mc.loads[hash]; ok {
+		return load
+	}
+
+	load := &memoryLoad{done: make(chan struct{})}
+	mc.loads[hash] = load
+	go func() {
+		var data []byte
+		reader, _, err := openSource(context.Background(), mc.httpClient(), key)
+		if err == nil {
+			data, err = io.ReadAll(reader)
+			reader.Close()
+		}
+
+		mc.lock.Lock()
+		delete(mc.loads, hash)
+		if err == nil {
+			mc.entries[hash] = &memoryEntry{data: data, accessedAt: time.Now()}
+			mc.size += int64(len(data))
+			mc.evict(hash)
+		}
+		mc.lock.Unlock()
+
+		load.err = err
+		close(load.done)
+		if err != nil {
+			logger.Logger.Error(err)
+		}
+	}()
+	return load
+}
+
+// evict removes the least recently used songs until the cache fits its size
+// limit, pinned songs and the song just added are kept. The lock must be held
+func (mc *MemoryCache) evict(added string) {
+	if mc.maxSize <= 0 || mc.size <= mc.maxSize {
+		return
+	}
+
+	hashes := make([]string, 0, len(mc.entries))
+	for hash := range mc.entries {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return mc.entries[hashes[i]].accessedAt.Before(mc.entries[hashes[j]].accessedAt)
+	})
+
+	for _, hash := range hashes {
+		if mc.size <= mc.maxSize {
+			return
+		}
+		if hash == added || mc.pinned[hash] {
+			continue
+		}
+		logger.Logger.Info("evict cache entry", hash)
+		mc.remove(hash)
+	}
+}
+
+// remove deletes a song, the lock must be held
+func (mc *MemoryCache) remove(hash string) {
+	if e, ok := mc.entries[hash]; ok {
+		mc.size -= int64(len(e.data))
+		delete(mc.entries, hash)
+	}
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"mmfm-playback-go/pkg/types"
+)
+
+// NopCache is a passthrough cache, the backends always play the original
+// location
+type NopCache struct{}
+
+// NewNopCache creates a new NopCache instance
+func NewNopCache() *NopCache {
+	return &NopCache{}
+}
+
+// Cache returns the key itself
+func (nc *NopCache) Cache(key string) string {
+	return key
+}
+
+// Fetch returns the key itself
+func (nc *NopCache) Fetch(ctx context.Context, key string) (string, error) {
+	return key, nil
+}
+
+// Has never finds a key
+func (nc *NopCache) Has(key string) bool {
+	return false
+}
+
+// Loudness is never known
+func (nc *NopCache) Loudness(key string) (*Loudness, bool) {
+	return nil, false
+}
+
+// Pin does nothing
+func (nc *NopCache) Pin(keys ...string) {}
+
+// Clean does nothing
+func (nc *NopCache) Clean(playlist []*types.Song) error {
+	return nil
+}
+
+// Flush does nothing
+func (nc *NopCache) Flush() error {
+	return nil
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"mmfm-playback-go/internal/logger"
+	"sync"
+	"time"
+)
+
+// Progress reports the state of a prefetch run
+type Progress struct {
+	Running bool  `json:"running"`
+	Total   int   `json:"total"`
+	Done    int   `json:"done"`
+	Failed  int   `json:"failed"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// rateLimiter spreads reads so they do not exceed a number of bytes per
+// second, it is shared by every download of a prefetcher
+type rateLimiter struct {
+	rate int64
+	next time.Time
+	lock sync.Mutex
+}
+
+// wait blocks until n more bytes may be transferred
+func (rl *rateLimiter) wait(n int) {
+	rl.lock.Lock()
+	now := time.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	delay := rl.next.Sub(now)
+	rl.next = rl.next.Add(time.Duration(int64(n) * int64(time.Second) / rl.rate))
+	rl.lock.Unlock()
+
+	time.Sleep(delay)
+}
+
+// throttledReader limits a download to the rate of its limiter while the
+// transfer is only wanted by the prefetcher
+type throttledReader struct {
+	reader   io.Reader
+	limiter  *rateLimiter
+	transfer *transfer
+	counter  func(n int)
+}
+
+// Read implements io.Reader
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > 32*1024 {
+		p = p[:32*1024]
+	}
+	n, err := tr.reader.Read(p)
+	if n > 0 {
+		tr.counter(n)
+		if tr.limiter != nil && tr.transfer.throttled.Load() {
+			tr.limiter.wait(n)
+		}
+	}
+	return n, err
+}
+
+// Prefetcher downloads whole playlists in background
+type Prefetcher struct {
+	cache       *FileCache
+	concurrency int
+	limiter     *rateLimiter
+	progress    Progress
+	cancel      context.CancelFunc
+	onProgress  func(Progress)
+	lock        sync.Mutex
+}
+
+// NewPrefetcher creates a new Prefetcher, a bytesPerSecond of zero does not
+// limit the bandwidth
+func NewPrefetcher(fc *FileCache, concurrency int, bytesPerSecond int64) *Prefetcher {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	p := &Prefetcher{
+		cache:       fc,
+		concurrency: concurrency,
+	}
+	if bytesPerSecond > 0 {
+		p.limiter = &rateLimiter{rate: bytesPerSecond}
+	}
+	return p
+}
+
+// OnProgress sets a callback for every finished download
+func (p *Prefetcher) OnProgress(callback func(Progress)) {
+	p.onProgress = callback
+}
+
+// Progress returns the state of the current run
+func (p *Prefetcher) Progress() Progress {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.progress
+}
+
+// Prefetch downloads keys in the given order, it replaces a run in progress
+func (p *Prefetcher) Prefetch(keys []string) {
+	p.lock.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.progress = Progress{Running: true, Total: len(keys)}
+	p.lock.Unlock()
+
+	queue := make(chan string)
+	var workers sync.WaitGroup
+	for i := 0; i < p.concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for key := range queue {
+				p.fetch(ctx, key)
+			}
+		}()
+	}
+
+	go func() {
+		defer func() {
+			close(queue)
+			workers.Wait()
+			p.finish(ctx)
+		}()
+		for _, key := range keys {
+			select {
+			case queue <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop cancels the run in progress
+func (p *Prefetcher) Stop() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.progress.Running = false
+}
+
+// fetch downloads a single key unless it is cached already
+func (p *Prefetcher) fetch(ctx context.Context, key string) {
+	if p.cache.Has(key) {
+		p.update(ctx, nil)
+		return
+	}
+
+	t := p.cache.startTransfer(key, true, p.limiter, func(n int) {
+		p.lock.Lock()
+		p.progress.Bytes += int64(n)
+		p.lock.Unlock()
+	})
+	select {
+	case <-t.done:
+		p.update(ctx, t.err)
+	case <-ctx.Done():
+	}
+}
+
+// update counts a finished download of the run of ctx
+func (p *Prefetcher) update(ctx context.Context, err error) {
+	p.lock.Lock()
+	if ctx.Err() != nil {
+		p.lock.Unlock()
+		return
+	}
+	if err != nil {
+		p.progress.Failed++
+	} else {
+		p.progress.Done++
+	}
+	progress := p.progress
+	p.lock.Unlock()
+
+	if p.onProgress != nil {
+		p.onProgress(progress)
+	}
+}
+
+// finish marks the run of ctx as completed
+func (p *Prefetcher) finish(ctx context.Context) {
+	p.lock.Lock()
+	if ctx.Err() != nil {
+		p.lock.Unlock()
+		return
+	}
+	p.progress.Running = false
+	progress := p.progress
+	p.lock.Unlock()
+
+	logger.Logger.Infof("prefetch finished, %d cached, %d failed", progress.Done, progress.Failed)
+	if p.onProgress != nil {
+		p.onProgress(progress)
+	}
+}
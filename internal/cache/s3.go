@@ -0,0 +1,408 @@
+package cache
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/logger"
+	"mmfm-playback-go/pkg/types"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// presignExpiry is how long a presigned playback URL stays valid
+	presignExpiry = time.Hour
+	// unsignedPayload replaces the payload hash of presigned URLs
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// emptyPayload is the SHA256 of an empty body
+	emptyPayload = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// bucketTimeout bounds a request to the bucket including an upload
+	bucketTimeout = 5 * time.Minute
+	// headTimeout bounds the existence check done before every playback
+	headTimeout = 5 * time.Second
+	// knownExpiry is how long an object is known to exist without asking
+	// the bucket again, lifecycle rules expire objects after days
+	knownExpiry = time.Hour
+)
+
+// S3Options describes an S3 compatible bucket
+type S3Options struct {
+	Endpoint  string // e.g. http://minio.local:9000
+	Bucket    string
+	Region    string // defaults to us-east-1
+	AccessKey string
+	SecretKey string
+	Prefix    string // Prepended to the object names
+}
+
+// S3Cache stores songs in an S3 compatible bucket shared by several
+// players, the backends play presigned URLs of the objects
+type S3Cache struct {
+	keying
+	requesting
+	options  S3Options
+	endpoint *url.URL
+	// bucket sends the requests to the bucket, which must not receive the
+	// credentials of the media servers
+	bucket  *http.Client
+	uploads map[string]*memoryLoad
+	// known holds when the objects were last seen in the bucket
+	known map[string]time.Time
+	lock  sync.Mutex
+	// now is replaced by tests
+	now func() time.Time
+}
+
+// NewS3Cache creates a new S3Cache instance
+func NewS3Cache(options S3Options) (*S3Cache, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(options.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", options.Endpoint)
+	}
+	if options.Bucket == "" {
+		return nil, errors.New("missing S3 bucket")
+	}
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+
+	return &S3Cache{
+		options:  options,
+		endpoint: endpoint,
+		bucket:   &http.Client{Timeout: bucketTimeout},
+		uploads:  make(map[string]*memoryLoad),
+		known:    make(map[string]time.Time),
+		now:      time.Now,
+	}, nil
+}
+
+// Cache returns a presigned URL of a cached key, a miss returns the key
+// itself while it is uploaded in background. The key is returned without an
+// upload when the bucket does not answer
+func (sc *S3Cache) Cache(key string) string {
+	exists, err := sc.exists(key)
+	if err != nil {
+		logger.Logger.Error(err)
+		return key
+	}
+	if exists {
+		return sc.presign(key)
+	}
+
+	sc.upload(key)
+	return key
+}
+
+// Fetch waits for key to be uploaded and returns a presigned URL of it
+func (sc *S3Cache) Fetch(ctx context.Context, key string) (string, error) {
+	exists, err := sc.exists(key)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return sc.presign(key), nil
+	}
+
+	upload := sc.upload(key)
+	select {
+	case <-upload.done:
+		if upload.err != nil {
+			return "", upload.err
+		}
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	return sc.presign(key), nil
+}
+
+// Has checks if the object of key exists in the bucket
+func (sc *S3Cache) Has(key string) bool {
+	exists, err := sc.exists(key)
+	if err != nil {
+		logger.Logger.Error(err)
+	}
+	return exists
+}
+
+// exists checks if the object of key exists, objects seen recently are not
+// requested again. An error means the bucket could not tell
+func (sc *S3Cache) exists(key string) (bool, error) {
+	hash := sc.hash(key)
+	sc.lock.Lock()
+	seen, ok := sc.known[hash]
+	sc.lock.Unlock()
+	if ok && sc.now().Sub(seen) < knownExpiry {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), headTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, sc.objectURL(key), nil)
+	if err != nil {
+		return false, err
+	}
+	sc.sign(req, emptyPayload, sc.now())
+	resp, err := sc.bucket.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		sc.remember(hash)
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("S3 lookup of %s returned %d", key, resp.StatusCode)
+}
+
+// remember marks the object of hash as existing
+func (sc *S3Cache) remember(hash string) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	sc.known[hash] = sc.now()
+}
+
+// Loudness is not measured by an S3Cache
+func (sc *S3Cache) Loudness(key string) (*Loudness, bool) {
+	return nil, false
+}
+
+// Pin does nothing, the objects are expired by the bucket lifecycle rules
+func (sc *S3Cache) Pin(keys ...string) {}
+
+// Clean does nothing as other players may still use the objects
+func (sc *S3Cache) Clean(playlist []*types.Song) error {
+	return nil
+}
+
+// Flush is not supported on a shared bucket
+func (sc *S3Cache) Flush() error {
+	return errors.New("flush is not supported by the S3 cache")
+}
+
+// upload returns the upload of key, an upload in progress is shared
+func (sc *S3Cache) upload(key string) *memoryLoad {
+	hash := sc.hash(key)
+
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if upload, ok := sc.uploads[hash]; ok {
+		return upload
+	}
+
+	upload := &memoryLoad{done: make(chan struct{})}
+	sc.uploads[hash] = upload
+	go func() {
+		upload.err = sc.put(key)
+		if upload.err == nil {
+			sc.remember(hash)
+		}
+
+		sc.lock.Lock()
+		delete(sc.uploads, hash)
+		sc.lock.Unlock()
+		close(upload.done)
+
+		if upload.err != nil {
+			logger.Logger.Error(upload.err)
+			return
+		}
+		logger.Logger.Debug("uploaded to S3 cache:", key)
+	}()
+	return upload
+}
+
+// put downloads key into a temporary file, which provides the length and
+// the payload hash of the signed upload
+func (sc *S3Cache) put(key string) error {
+	reader, _, err := openSource(context.Background(), sc.httpClient(), key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	temp, err := os.CreateTemp("", "mmfm-s3-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	payloadHash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(temp, payloadHash), reader)
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, sc.objectURL(key), temp)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	sc.sign(req, hex.EncodeToString(payloadHash.Sum(nil)), sc.now())
+	resp, err := sc.bucket.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 upload of %s returned %d: %s", key, resp.StatusCode, body)
+	}
+	return nil
+}
+
+// objectURL returns the path style URL of the object of key
+func (sc *S3Cache) objectURL(key string) string {
+	return fmt.Sprintf("%s/%s/%s%s", sc.endpoint, sc.options.Bucket, sc.options.Prefix, sc.hash(key))
+}
+
+// presign returns a URL of the object of key which can be played without
+// credentials until it expires
+func (sc *S3Cache) presign(key string) string {
+	now := sc.now().UTC()
+	target, _ := url.Parse(sc.objectURL(key))
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", sc.options.AccessKey+"/"+sc.scope(now))
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", fmt.Sprint(int(presignExpiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	target.RawQuery = canonicalQuery(query)
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		uriEncode(target.Path, false),
+		target.RawQuery,
+		"host:" + target.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	query.Set("X-Amz-Signature", sc.signature(canonical, now))
+	target.RawQuery = canonicalQuery(query)
+	return target.String()
+}
+
+// sign adds an AWS signature version 4 Authorization header to req, every
+// header already set on req is signed
+func (sc *S3Cache) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		sc.options.AccessKey, sc.scope(now), signedHeaders, sc.signature(canonical, now),
+	))
+}
+
+// scope returns the credential scope of a request made at now
+func (sc *S3Cache) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + sc.options.Region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request made at now
+func (sc *S3Cache) signature(canonical string, now time.Time) string {
+	requestHash := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		sc.scope(now),
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+sc.options.SecretKey), now.Format("20060102"))
+	for _, part := range []string{sc.options.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// hmacSHA256 computes the HMAC of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes a query sorted by name as required by signatures
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := []string{}
+	for _, name := range names {
+		values := append([]string{}, query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent encodes everything but the unreserved characters, the
+// slashes of a path are kept unless encodeSlash is set
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, c := range []byte(value) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/logger"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// streamPrefix is the path of the local streaming endpoint
+const streamPrefix = "/stream/"
+
+// opener returns a reader of a cache entry and its size, -1 if unknown
+type opener func(ctx context.Context, key string) (io.ReadCloser, int64, error)
+
+// localServer serves cache entries to the backends over HTTP on the
+// loopback interface, it is started on first use
+type localServer struct {
+	open opener
+	addr string
+	keys map[string]string
+	lock sync.Mutex
+}
+
+// newLocalServer creates a new localServer instance
+func newLocalServer(open opener) *localServer {
+	return &localServer{
+		open: open,
+		keys: make(map[string]string),
+	}
+}
+
+// url returns the local URL of key
+func (ls *localServer) url(key string) (string, error) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+
+	if ls.addr == "" {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		ls.addr = listener.Addr().String()
+		go func() {
+			err := http.Serve(listener, http.HandlerFunc(ls.serve))
+			logger.Logger.Error(err)
+		}()
+		logger.Logger.Info("cache stream endpoint listening on", ls.addr)
+	}
+
+	hashKey := hashKey(key)
+	ls.keys[hashKey] = key
+	return fmt.Sprintf("http://%s%s%s", ls.addr, streamPrefix, hashKey), nil
+}
+
+// serve writes a cache entry, following its download if necessary
+func (ls *localServer) serve(w http.ResponseWriter, r *http.Request) {
+	hashKey := strings.TrimPrefix(r.URL.Path, streamPrefix)
+
+	ls.lock.Lock()
+	key, ok := ls.keys[hashKey]
+	ls.lock.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	reader, size, err := ls.open(r.Context(), key)
+	if err != nil {
+		logger.Logger.Error(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer reader.Close()
+
+	if size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, reader); err != nil {
+		logger.Logger.Debug("stream of", key, "ended:", err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"mmfm-playback-go/internal/logger"
 	"mmfm-playback-go/pkg/types"
 	"strings"
+	"sync"
 )
 
 // MessageArgs represents arguments for a message
@@ -30,13 +31,19 @@ func (ma *MessageArgs) ToJSON() (string, error) {
 }
 
 const (
-	EVENT_PLAYING      = "player.playing"
-	EVENT_PAUSE        = "player.pause"
-	EVENT_CURRENT      = "player.current"
-	EVENT_CONTINUE     = "player.continue"
-	EVENT_PLAY         = "player.play"
-	EVENT_UPDATE       = "update"
-	CHAT_EVENT_MESSAGE = "msg"
+	EVENT_PLAYING        = "player.playing"
+	EVENT_PAUSE          = "player.pause"
+	EVENT_CURRENT        = "player.current"
+	EVENT_CONTINUE       = "player.continue"
+	EVENT_PLAY           = "player.play"
+	EVENT_VOLUME         = "player.volume"
+	EVENT_MUTE           = "player.mute"
+	EVENT_UNMUTE         = "player.unmute"
+	EVENT_OUTPUT         = "player.output"
+	EVENT_UPDATE         = "update"
+	EVENT_CACHE_PROGRESS = "cache.progress"
+	EVENT_NOW_PLAYING    = "player.nowplaying"
+	CHAT_EVENT_MESSAGE   = "msg"
 )
 
 // PlayingEvent represents playing event data
@@ -99,6 +106,8 @@ type ChatClient struct {
 	client            *gosocketio.Client
 	listener          chan *MessageArgs
 	connectedCallback func()
+	closed            bool
+	lock              sync.Mutex
 }
 
 // NewChatClient creates a new ChatClient instance
@@ -111,26 +120,43 @@ func NewChatClient(url string) *ChatClient {
 
 // Connect establishes connection to the chat server
 func (cc *ChatClient) Connect() error {
-	var err error
-	cc.client, err = gosocketio.Dial(
-		cc.url,
+	cc.lock.Lock()
+	url := cc.url
+	cc.lock.Unlock()
+
+	client, err := gosocketio.Dial(
+		url,
 		transport.GetDefaultWebsocketTransport())
 	if err != nil {
 		logger.Logger.Error(err)
 		return err
 	}
 
+	cc.lock.Lock()
+	cc.client = client
+	cc.lock.Unlock()
 	return nil
 }
 
+// connection returns the current connection, nil before the first Connect
+func (cc *ChatClient) connection() *gosocketio.Client {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	return cc.client
+}
+
 // Listen starts listening for messages
 func (cc *ChatClient) Listen() (chan *MessageArgs, error) {
+	if cc.isClosed() {
+		return nil, errors.New("chat client is closed")
+	}
 	err := cc.Connect()
 	if err != nil {
 		return nil, err
 	}
 
-	err = cc.client.On(gosocketio.OnConnection, func(h *gosocketio.Channel) {
+	client := cc.connection()
+	err = client.On(gosocketio.OnConnection, func(h *gosocketio.Channel) {
 		logger.Logger.Info("connected")
 		if cc.connectedCallback != nil {
 			cc.connectedCallback()
@@ -142,10 +168,13 @@ func (cc *ChatClient) Listen() (chan *MessageArgs, error) {
 		return nil, err
 	}
 
-	err = cc.client.On(gosocketio.OnDisconnection, func(h *gosocketio.Channel) {
+	err = client.On(gosocketio.OnDisconnection, func(h *gosocketio.Channel) {
 		logger.Logger.Info("Disconnected")
+		if cc.isClosed() {
+			return
+		}
 
-		defer cc.client.Close()
+		defer client.Close()
 		defer cc.Listen()
 	})
 
@@ -154,7 +183,7 @@ func (cc *ChatClient) Listen() (chan *MessageArgs, error) {
 		return nil, err
 	}
 
-	err = cc.client.On(CHAT_EVENT_MESSAGE, func(h *gosocketio.Channel, sourceParams string) {
+	err = client.On(CHAT_EVENT_MESSAGE, func(h *gosocketio.Channel, sourceParams string) {
 		logger.Logger.Debug("--- Got chat message: ", sourceParams)
 
 		cc.listener <- ParseMessageArgs(sourceParams)
@@ -168,13 +197,39 @@ func (cc *ChatClient) Listen() (chan *MessageArgs, error) {
 	return cc.listener, nil
 }
 
-// Close closes the chat connection
+// Close closes the chat connection for good, it is not reconnected
 func (cc *ChatClient) Close(callbackList ...func()) {
-	defer cc.client.Close()
+	cc.lock.Lock()
+	cc.closed = true
+	client := cc.client
+	cc.lock.Unlock()
 
 	for _, callback := range callbackList {
 		callback()
 	}
+	if client != nil {
+		client.Close()
+	}
+}
+
+// isClosed checks if Close has been called
+func (cc *ChatClient) isClosed() bool {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	return cc.closed
+}
+
+// SetURL switches to the chat server at url, an open connection is closed
+// and reconnects to it
+func (cc *ChatClient) SetURL(url string) {
+	cc.lock.Lock()
+	cc.url = url
+	client := cc.client
+	cc.lock.Unlock()
+
+	if client != nil {
+		client.Close()
+	}
 }
 
 // OnConnected sets a callback for when connected
@@ -184,7 +239,8 @@ func (cc *ChatClient) OnConnected(callback func()) {
 
 // SendEvent sends an event to the chat server
 func (cc *ChatClient) SendEvent(eventName string, params *MessageArgs) error {
-	if cc.client == nil {
+	client := cc.connection()
+	if client == nil {
 		return errors.New("client connection is not ready")
 	}
 	args, err := params.ToJSON()
@@ -192,5 +248,5 @@ func (cc *ChatClient) SendEvent(eventName string, params *MessageArgs) error {
 		logger.Logger.Error(err)
 		return err
 	}
-	return cc.client.Emit(eventName, args)
+	return client.Emit(eventName, args)
 }
@@ -4,7 +4,41 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
+)
+
+// Playback backends
+const (
+	BackendMPlayer = "mplayer"
+	BackendFFPlay  = "ffplay"
+	BackendNative  = "native"
+)
+
+// Audio output drivers
+const (
+	AudioALSA     = "alsa"
+	AudioPulse    = "pulse"
+	AudioPipeWire = "pipewire"
+	AudioWAV      = "wav"
+	AudioNull     = "null"
+)
+
+// Resume modes of the playback saved at shutdown
+const (
+	ResumePosition = "position"
+	ResumeSong     = "song"
+	ResumeOff      = "off"
+)
+
+// Cache storage types
+const (
+	CacheFile   = "file"
+	CacheMemory = "memory"
+	CacheNone   = "none"
+	CacheS3     = "s3"
 )
 
 // FFmpegConfig holds FFmpeg related configuration
@@ -12,6 +46,116 @@ type FFmpegConfig struct {
 	FFPlay  string `json:"ffplay"`
 	FFProbe string `json:"ffprobe"`
 	MPlayer string `json:"mplayer"`
+	FFMpeg  string `json:"ffmpeg,omitempty"`
+}
+
+// CacheConfig holds the cache behaviour configuration
+type CacheConfig struct {
+	Type          string    `json:"type,omitempty"`           // file (default), memory, none or s3
+	S3            *S3Config `json:"s3,omitempty"`             // Bucket of the s3 type
+	Mode          string    `json:"mode,omitempty"`           // async (default), sync or stream
+	Key           string    `json:"key,omitempty"`            // url (default), query, id or etag
+	KeyParams     []string  `json:"key_params,omitempty"`     // Query parameters ignored by the query key, all if empty
+	Dedup         bool      `json:"dedup,omitempty"`          // Store identical files under different URLs once
+	MaxSize       string    `json:"max_size,omitempty"`       // Size limit, e.g. 2GB or 500MB
+	MaxAge        string    `json:"max_age,omitempty"`        // Evict entries not played for this duration, e.g. 720h
+	EvictInterval string    `json:"evict_interval,omitempty"` // How often the limits are enforced, defaults to 10m
+	// Prefetch downloads the whole playlist in background
+	Prefetch            bool   `json:"prefetch,omitempty"`
+	PrefetchConcurrency int    `json:"prefetch_concurrency,omitempty"` // Parallel downloads, defaults to 1
+	PrefetchRate        string `json:"prefetch_rate,omitempty"`        // Bytes per second shared by all downloads, e.g. 512KB
+}
+
+// S3Config holds the bucket of a shared S3 compatible cache
+type S3Config struct {
+	Endpoint  string `json:"endpoint"` // e.g. http://minio.local:9000
+	Bucket    string `json:"bucket"`
+	Region    string `json:"region,omitempty"` // Defaults to us-east-1
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	Prefix    string `json:"prefix,omitempty"` // Prepended to the object names
+}
+
+// check records the problems of the cache options
+func (cc *CacheConfig) check(ps *problems) {
+	ps.oneOf("cache_options.type", cc.Type, CacheFile, CacheMemory, CacheNone, CacheS3)
+	if cc.Type == CacheS3 {
+		if cc.S3 == nil || cc.S3.Endpoint == "" || cc.S3.Bucket == "" {
+			ps.add("cache_options.s3", "requires endpoint and bucket", "set cache_options.s3.endpoint and cache_options.s3.bucket")
+		} else {
+			checkURL(ps, "cache_options.s3.endpoint", cc.S3.Endpoint, "http", "https")
+		}
+	}
+	ps.oneOf("cache_options.key", cc.Key, "url", "query", "id", "etag")
+	ps.oneOf("cache_options.mode", cc.Mode, "async", "sync", "stream")
+	checkSize(ps, "cache_options.max_size", cc.MaxSize)
+	checkDuration(ps, "cache_options.max_age", cc.MaxAge)
+	checkDuration(ps, "cache_options.evict_interval", cc.EvictInterval)
+	checkSize(ps, "cache_options.prefetch_rate", cc.PrefetchRate)
+	if cc.PrefetchConcurrency < 0 {
+		ps.add("cache_options.prefetch_concurrency", "must not be negative", "use 1 or more parallel downloads")
+	}
+}
+
+// Limits returns the parsed size and age limits, zero means unlimited
+func (cc *CacheConfig) Limits() (int64, time.Duration, error) {
+	var maxSize int64
+	var maxAge time.Duration
+	var err error
+
+	if cc.MaxSize != "" {
+		if maxSize, err = ParseSize(cc.MaxSize); err != nil {
+			return 0, 0, fmt.Errorf("cache_options.max_size: %w", err)
+		}
+	}
+	if cc.MaxAge != "" {
+		if maxAge, err = time.ParseDuration(cc.MaxAge); err != nil {
+			return 0, 0, fmt.Errorf("cache_options.max_age: %w", err)
+		}
+	}
+	return maxSize, maxAge, nil
+}
+
+// Interval returns how often the limits are enforced
+func (cc *CacheConfig) Interval() time.Duration {
+	interval, err := time.ParseDuration(cc.EvictInterval)
+	if err != nil || interval <= 0 {
+		return 10 * time.Minute
+	}
+	return interval
+}
+
+// ParseSize parses a byte size with an optional KB, MB or GB suffix
+func ParseSize(size string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// AudioConfig selects the audio output of the backends
+type AudioConfig struct {
+	Driver string `json:"driver,omitempty"` // alsa, pulse, pipewire, wav or null, empty for the backend default
+	Device string `json:"device,omitempty"` // ALSA PCM such as hw:CARD=Device,DEV=0, the PulseAudio/PipeWire sink name or the wav file
+}
+
+// LoudnessConfig holds the loudness normalization configuration
+type LoudnessConfig struct {
+	Target float64 `json:"target"` // Target integrated loudness in LUFS, e.g. -16
 }
 
 // ScheduledAudio represents a scheduled audio playback configuration
@@ -23,12 +167,41 @@ type ScheduledAudio struct {
 
 // PlaybackConfig holds the main configuration for the playback service
 type PlaybackConfig struct {
-	FFMpegConf      *FFmpegConfig    `json:"ffmpeg"`
-	WebSocketAPI    string           `json:"ws"`
-	WebAPI          string           `json:"web"`
-	CachePath       string           `json:"cache"`
-	ScheduledAudios []ScheduledAudio `json:"scheduled_audios,omitempty"`
-	configFile      string
+	FFMpegConf       *FFmpegConfig    `json:"ffmpeg"`
+	Backend          string           `json:"backend,omitempty"` // mplayer (default), ffplay or native
+	Audio            *AudioConfig     `json:"audio,omitempty"`
+	WebSocketAPI     string           `json:"ws"`
+	WebAPI           string           `json:"web"`
+	PlaylistType     string           `json:"playlist_type,omitempty"`     // json, m3u, dir or feed, detected from web when empty
+	PlaylistFallback string           `json:"playlist_fallback,omitempty"` // Playlist location used while web is unavailable, e.g. a USB music folder
+	PlaylistPoll     string           `json:"playlist_poll,omitempty"`     // How often the playlist is polled, e.g. 5m, empty disables polling
+	CachePath        string           `json:"cache"`
+	CacheOptions     *CacheConfig     `json:"cache_options,omitempty"`
+	HTTP             *HTTPConfig      `json:"http,omitempty"`
+	API              string           `json:"api,omitempty"` // Listen address of the local status API, e.g. 127.0.0.1:8090
+	ScheduledAudios  []ScheduledAudio `json:"scheduled_audios,omitempty"`
+	Crossfade        float64          `json:"crossfade,omitempty"` // Seconds the end of a song overlaps the next one, 0 disables it
+	Loudness         *LoudnessConfig  `json:"loudness,omitempty"`
+	VolumeSchedules  []VolumeSchedule `json:"volume_schedules,omitempty"`
+	VolumeRamp       float64          `json:"volume_ramp,omitempty"` // Seconds to ramp between volume schedules
+	OperatingHours   *OperatingHours  `json:"operating_hours,omitempty"`
+	Resume           string           `json:"resume,omitempty"` // position (default), song from its start or off to start from the first song
+	configFile       string
+	// templates holds the fields which contained placeholders by path
+	templates map[string]template
+	// unresolved and unknown are the problems found while loading the file
+	unresolved problems
+	unknown    problems
+}
+
+// PollInterval returns how often the playlist is polled, zero disables
+// polling
+func (c *PlaybackConfig) PollInterval() time.Duration {
+	interval, err := time.ParseDuration(c.PlaylistPoll)
+	if err != nil || interval < 0 {
+		return 0
+	}
+	return interval
 }
 
 // NewConfig creates a new configuration from file or environment variables
@@ -42,10 +215,15 @@ func NewConfig(filename string) (*PlaybackConfig, error) {
 	if err := c.loadFromFile(filename); err != nil {
 		fmt.Printf("Warning: Could not load config from file %s: %v\n", filename, err)
 		fmt.Println("Falling back to environment variables...")
+	} else if err := c.interpolate(); err != nil {
+		return nil, fmt.Errorf("configuration interpolation failed: %w", err)
 	}
 
 	// Override with environment variables if present
 	c.loadFromEnv()
+	if err := c.loadFromPrefixedEnv(); err != nil {
+		return nil, fmt.Errorf("invalid environment variable: %w", err)
+	}
 
 	// Validate required fields
 	if err := c.validate(); err != nil {
@@ -55,19 +233,69 @@ func NewConfig(filename string) (*PlaybackConfig, error) {
 	return c, nil
 }
 
-// loadFromFile loads configuration from a JSON file
+// Load reads the configuration file and the environment without validating
+// the result, the problems are reported by Validate
+func Load(filename string) (*PlaybackConfig, error) {
+	c := &PlaybackConfig{
+		FFMpegConf: &FFmpegConfig{},
+		configFile: filename,
+	}
+	if err := c.loadFromFile(filename); err != nil {
+		return nil, err
+	}
+	// Unresolved variables are among the problems
+	c.interpolate()
+	c.loadFromEnv()
+	if err := c.loadFromPrefixedEnv(); err != nil {
+		return nil, fmt.Errorf("invalid environment variable: %w", err)
+	}
+	return c, nil
+}
+
+// Reload loads the configuration file again, unlike NewConfig it fails
+// when the file can not be read
+func Reload(filename string) (*PlaybackConfig, error) {
+	c := &PlaybackConfig{
+		FFMpegConf: &FFmpegConfig{},
+		configFile: filename,
+	}
+	if err := c.loadFromFile(filename); err != nil {
+		return nil, err
+	}
+	if err := c.interpolate(); err != nil {
+		return nil, fmt.Errorf("configuration interpolation failed: %w", err)
+	}
+	c.loadFromEnv()
+	if err := c.loadFromPrefixedEnv(); err != nil {
+		return nil, fmt.Errorf("invalid environment variable: %w", err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+	return c, nil
+}
+
+// loadFromFile loads configuration from a JSON, YAML or TOML file, the
+// format is detected by the extension
 func (c *PlaybackConfig) loadFromFile(filename string) error {
-	file, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		return fmt.Errorf("could not open config file: %w", err)
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(c); err != nil {
+	format := DetectFormat(filename)
+	if err := decode(format, data, c); err != nil {
 		return fmt.Errorf("could not decode config file: %w", err)
 	}
 
+	// Misspelled fields are ignored by the decoder, they are reported by
+	// Validate
+	var document interface{}
+	if err := decode(format, data, &document); err == nil {
+		c.unknown = nil
+		unknownFields(&c.unknown, document, reflect.TypeOf(c), "")
+	}
+
 	return nil
 }
 
@@ -83,6 +311,25 @@ func (c *PlaybackConfig) loadFromEnv() {
 	if mplayer := os.Getenv("MPLAYER_PATH"); mplayer != "" {
 		c.FFMpegConf.MPlayer = mplayer
 	}
+	if ffmpeg := os.Getenv("FFMPEG_PATH"); ffmpeg != "" {
+		c.FFMpegConf.FFMpeg = ffmpeg
+	}
+
+	if backend := os.Getenv("PLAYER_BACKEND"); backend != "" {
+		c.Backend = backend
+	}
+	if driver := os.Getenv("AUDIO_DRIVER"); driver != "" {
+		if c.Audio == nil {
+			c.Audio = &AudioConfig{}
+		}
+		c.Audio.Driver = driver
+	}
+	if device := os.Getenv("AUDIO_DEVICE"); device != "" {
+		if c.Audio == nil {
+			c.Audio = &AudioConfig{}
+		}
+		c.Audio.Device = device
+	}
 
 	// API endpoints
 	if wsAPI := os.Getenv("WEBSOCKET_API"); wsAPI != "" {
@@ -92,11 +339,37 @@ func (c *PlaybackConfig) loadFromEnv() {
 		c.WebAPI = webAPI
 	}
 
+	if fallback := os.Getenv("PLAYLIST_FALLBACK"); fallback != "" {
+		c.PlaylistFallback = fallback
+	}
+	if poll := os.Getenv("PLAYLIST_POLL"); poll != "" {
+		c.PlaylistPoll = poll
+	}
+
 	// Cache path
 	if cachePath := os.Getenv("CACHE_PATH"); cachePath != "" {
 		c.CachePath = cachePath
 	}
 
+	// Authentication of the web API and media requests
+	if token := os.Getenv("HTTP_BEARER_TOKEN"); token != "" {
+		if c.HTTP == nil {
+			c.HTTP = &HTTPConfig{}
+		}
+		c.HTTP.BearerToken = token
+	}
+
+	if resume := os.Getenv("RESUME"); resume != "" {
+		c.Resume = resume
+	}
+
+	// Crossfade duration in seconds
+	if crossfade := os.Getenv("CROSSFADE"); crossfade != "" {
+		if seconds, err := strconv.ParseFloat(crossfade, 64); err == nil {
+			c.Crossfade = seconds
+		}
+	}
+
 	// Support for legacy environment variable names as well
 	if ws := os.Getenv("WS_API"); ws != "" {
 		c.WebSocketAPI = ws
@@ -109,46 +382,29 @@ func (c *PlaybackConfig) loadFromEnv() {
 	}
 }
 
-// validate checks if required configuration fields are present
-func (c *PlaybackConfig) validate() error {
-	var missingFields []string
-
-	if c.FFMpegConf.FFPlay == "" {
-		missingFields = append(missingFields, "ffmpeg.ffplay")
-	}
-	if c.FFMpegConf.FFProbe == "" {
-		missingFields = append(missingFields, "ffmpeg.ffprobe")
-	}
-	if c.WebSocketAPI == "" {
-		missingFields = append(missingFields, "ws")
-	}
-	if c.WebAPI == "" {
-		missingFields = append(missingFields, "web")
-	}
-	if c.CachePath == "" {
-		missingFields = append(missingFields, "cache")
+// Save saves the configuration to its file in the format of the file, fields
+// loaded from ${VAR} placeholders are written back as placeholders unless
+// they changed
+func (c *PlaybackConfig) Save() error {
+	// The templates are restored on a copy, the running config keeps the
+	// expanded values
+	saved, err := c.clone()
+	if err != nil {
+		return fmt.Errorf("could not copy config: %w", err)
 	}
+	saved.restoreTemplates()
 
-	if len(missingFields) > 0 {
-		return fmt.Errorf("missing required configuration fields: %s", strings.Join(missingFields, ", "))
+	data, err := encode(DetectFormat(c.configFile), saved)
+	if err != nil {
+		return fmt.Errorf("could not marshal config: %w", err)
 	}
 
-	return nil
-}
-
-// Save saves the configuration to the JSON file
-func (c *PlaybackConfig) Save() error {
 	file, err := os.Create(c.configFile)
 	if err != nil {
 		return fmt.Errorf("could not create config file: %w", err)
 	}
 	defer file.Close()
 
-	data, err := json.MarshalIndent(c, "", "    ")
-	if err != nil {
-		return fmt.Errorf("could not marshal config: %w", err)
-	}
-
 	if _, err := file.Write(data); err != nil {
 		return fmt.Errorf("could not write config to file: %w", err)
 	}
@@ -156,6 +412,22 @@ func (c *PlaybackConfig) Save() error {
 	return nil
 }
 
+// clone returns a deep copy of the configuration
+func (c *PlaybackConfig) clone() (*PlaybackConfig, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	copied := &PlaybackConfig{
+		configFile: c.configFile,
+		templates:  c.templates,
+	}
+	if err := json.Unmarshal(data, copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
 // GetConfigPath returns the path of the configuration file
 func (c *PlaybackConfig) GetConfigPath() string {
 	return c.configFile
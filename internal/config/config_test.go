@@ -3,7 +3,10 @@ package config
 import (
 	"mmfm-playback-go/tests"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewConfigFromFile(t *testing.T) {
@@ -59,7 +62,7 @@ func TestConfigFromEnvironment(t *testing.T) {
 }`
 
 	tempFile := "test_env_config.json"
-	err := os.WriteFile(tempFile, []byte(tempConfig), 0644)
+	err = os.WriteFile(tempFile, []byte(tempConfig), 0644)
 	if err != nil {
 		t.Fatal("Failed to create temp config file:", err)
 	}
@@ -103,4 +106,281 @@ func TestConfigValidation(t *testing.T) {
 	if err == nil {
 		t.Error("Expected validation error for missing required fields, but got none")
 	}
+
+	// The native backend runs neither ffprobe nor a player
+	for _, name := range []string{"FFPROBE_PATH", "MPLAYER_PATH", "FFPLAY_PATH"} {
+		t.Setenv(name, "")
+	}
+	native := &PlaybackConfig{FFMpegConf: &FFmpegConfig{}, Backend: BackendNative, WebSocketAPI: "ws://mmfm", WebAPI: "http://mmfm/song/get", CachePath: t.TempDir()}
+	if err := native.Validate(); err != nil {
+		t.Errorf("Expected the native backend to need no binaries, got %v", err)
+	}
+	native.Crossfade = 3
+	if err := native.Validate(); err == nil || !strings.Contains(err.Error(), "crossfade") {
+		t.Errorf("Expected the native backend to reject the crossfade, got %v", err)
+	}
+	native.Crossfade = 0
+	native.Audio = &AudioConfig{Driver: AudioPulse}
+	if err := native.Validate(); err == nil {
+		t.Error("Expected the native backend to reject pulse")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"1024":  1024,
+		"2GB":   2 << 30,
+		"500mb": 500 << 20,
+		"1.5KB": 1536,
+	}
+	for size, expected := range cases {
+		value, err := ParseSize(size)
+		if err != nil {
+			t.Errorf("ParseSize(%q) should not return error: %v", size, err)
+			continue
+		}
+		if value != expected {
+			t.Errorf("Expected %q to be %d bytes, got %d", size, expected, value)
+		}
+	}
+
+	if _, err := ParseSize("big"); err == nil {
+		t.Error("Expected error for invalid size")
+	}
+}
+
+func TestConfigInterpolation(t *testing.T) {
+	t.Setenv("MMFM_TEST_HOST", "mmfm.local")
+	t.Setenv("MMFM_TEST_EMPTY", "")
+	// Overrides of the test environment would hide the expanded values
+	for _, name := range []string{"FFPLAY_PATH", "MPLAYER_PATH", "WEBSOCKET_API", "WEB_API"} {
+		t.Setenv(name, "")
+	}
+	tempFile := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(tempFile, []byte(`{
+    "ffmpeg": {"ffplay": "${MMFM_TEST_FFPLAY:-/usr/bin/ffplay}", "ffprobe": "/usr/bin/ffprobe", "mplayer": "${MMFM_TEST_EMPTY:-mplayer}"},
+    "ws": "ws://${MMFM_TEST_HOST}/io/",
+    "web": "http://${MMFM_TEST_HOST}/song/get",
+    "cache": "./cache"
+}`), 0644)
+
+	config, err := NewConfig(tempFile)
+	if err != nil {
+		t.Fatal("Failed to load config:", err)
+	}
+	if config.WebAPI != "http://mmfm.local/song/get" || config.FFMpegConf.FFPlay != "/usr/bin/ffplay" || config.FFMpegConf.MPlayer != "mplayer" {
+		t.Errorf("Expected expanded values, got %s %s %s", config.WebAPI, config.FFMpegConf.FFPlay, config.FFMpegConf.MPlayer)
+	}
+
+	config.WebSocketAPI = "ws://changed/io/"
+	if err := config.Save(); err != nil {
+		t.Fatal("Save should not return error:", err)
+	}
+	saved, _ := os.ReadFile(tempFile)
+	if !strings.Contains(string(saved), `"http://${MMFM_TEST_HOST}/song/get"`) || !strings.Contains(string(saved), `"ws://changed/io/"`) {
+		t.Errorf("Expected the templates and changed values to be saved, got %s", saved)
+	}
+	if config.WebAPI != "http://mmfm.local/song/get" {
+		t.Error("Save should not change the running config")
+	}
+
+	os.WriteFile(tempFile, []byte(`{"ws": "${MMFM_TEST_MISSING_B}", "web": "${MMFM_TEST_MISSING_A}/${MMFM_TEST_MISSING_B}"}`), 0644)
+	if _, err := NewConfig(tempFile); err == nil || !strings.Contains(err.Error(), "MMFM_TEST_MISSING_A, MMFM_TEST_MISSING_B") {
+		t.Errorf("Expected the unresolved variables to be listed, got %v", err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	t.Setenv("WEBSOCKET_API", "")
+	tempFile := filepath.Join(t.TempDir(), "config.json")
+	write := func(ws string) {
+		os.WriteFile(tempFile, []byte(`{"ffmpeg": {"ffprobe": "ffprobe", "mplayer": "mplayer"}, "ws": "`+ws+`", "web": "http://mmfm/song/get", "cache": "./cache"}`), 0644)
+	}
+	write("ws://first")
+
+	reloads := make(chan *PlaybackConfig, 1)
+	watcher := NewWatcher(tempFile, func(conf *PlaybackConfig) {
+		reloads <- conf
+	})
+	watcher.interval = 10 * time.Millisecond
+	watcher.Start()
+
+	write("ws://second")
+	select {
+	case conf := <-reloads:
+		if conf.WebSocketAPI != "ws://second" {
+			t.Errorf("Expected the changed config, got %s", conf.WebSocketAPI)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the changed config to be reloaded")
+	}
+
+	write("")
+	select {
+	case conf := <-reloads:
+		t.Errorf("Expected the invalid config to be rejected, got %s", tests.ToJSON(conf))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestConfigFormats(t *testing.T) {
+	for _, name := range []string{"FFPLAY_PATH", "WEBSOCKET_API"} {
+		t.Setenv(name, "")
+	}
+	documents := map[string]string{
+		"config.yaml": `
+ffmpeg:
+  ffplay: /usr/bin/ffplay
+  ffprobe: /usr/bin/ffprobe
+  mplayer: /usr/bin/mplayer
+ws: ws://yaml
+web: http://mmfm/song/get
+cache: ./cache
+scheduled_audios:
+  - name: Opening
+    url: http://mmfm/opening.mp3
+    schedule: "09:00"
+`,
+		"config.toml": `
+ws = "ws://toml"
+web = "http://mmfm/song/get"
+cache = "./cache"
+
+[ffmpeg]
+ffplay = "/usr/bin/ffplay"
+ffprobe = "/usr/bin/ffprobe"
+mplayer = "/usr/bin/mplayer"
+
+[[scheduled_audios]]
+name = "Opening"
+url = "http://mmfm/opening.mp3"
+schedule = "09:00"
+`,
+	}
+	for name, document := range documents {
+		tempFile := filepath.Join(t.TempDir(), name)
+		os.WriteFile(tempFile, []byte(document), 0644)
+
+		config, err := NewConfig(tempFile)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", name, err)
+		}
+		if config.FFMpegConf.FFPlay != "/usr/bin/ffplay" || len(config.ScheduledAudios) != 1 || config.ScheduledAudios[0].Schedule != "09:00" {
+			t.Errorf("Expected the %s fields, got %s", name, tests.ToJSON(config))
+		}
+
+		// Saving keeps the format of the file
+		if err := config.Save(); err != nil {
+			t.Fatalf("Failed to save %s: %v", name, err)
+		}
+		saved, err := NewConfig(tempFile)
+		if err != nil || saved.WebSocketAPI != config.WebSocketAPI {
+			t.Errorf("Expected the saved %s to load, got %v", name, err)
+		}
+	}
+}
+
+func TestPrefixedEnv(t *testing.T) {
+	t.Setenv("MMFM_CROSSFADE", "4.5")
+	t.Setenv("MMFM_CACHE_OPTIONS_PREFETCH", "true")
+	t.Setenv("MMFM_CACHE_OPTIONS_KEY_PARAMS", "token, expires")
+	t.Setenv("MMFM_SCHEDULED_AUDIOS_1_URL", "http://mmfm/closing.mp3")
+	t.Setenv("MMFM_SCHEDULED_AUDIOS_1_SCHEDULE", "21:00")
+	t.Setenv("MMFM_HTTP_HEADERS_X_SITE", "shop1")
+
+	config := &PlaybackConfig{
+		ScheduledAudios: []ScheduledAudio{{Name: "Opening", Schedule: "09:00"}},
+	}
+	if err := config.loadFromPrefixedEnv(); err != nil {
+		t.Fatal("loadFromPrefixedEnv should not return error:", err)
+	}
+	if config.Crossfade != 4.5 || !config.CacheOptions.Prefetch || len(config.CacheOptions.KeyParams) != 2 || config.CacheOptions.KeyParams[1] != "expires" {
+		t.Errorf("Expected the scalar and list fields, got %s", tests.ToJSON(config))
+	}
+	if len(config.ScheduledAudios) != 2 || config.ScheduledAudios[0].Name != "Opening" || config.ScheduledAudios[1].Schedule != "21:00" {
+		t.Errorf("Expected the list entry to be added, got %s", tests.ToJSON(config.ScheduledAudios))
+	}
+	if config.HTTP.Headers["x-site"] != "shop1" {
+		t.Errorf("Expected the map entry, got %s", tests.ToJSON(config.HTTP))
+	}
+
+	t.Setenv("MMFM_CROSSFADE", "long")
+	if err := config.loadFromPrefixedEnv(); err == nil {
+		t.Error("Expected an invalid number to be rejected")
+	}
+}
+
+func TestMasked(t *testing.T) {
+	config := &PlaybackConfig{
+		WebSocketAPI: "ws://user:secret@mmfm/io/",
+		HTTP: &HTTPConfig{
+			BearerToken: "token",
+			Headers:     map[string]string{"X-Api-Key": "key", "X-Site": "shop1"},
+		},
+	}
+	masked, err := config.Masked()
+	if err != nil {
+		t.Fatal("Masked should not return error:", err)
+	}
+	output := tests.ToJSON(masked)
+	for _, secret := range []string{"secret", `"token"`, `"key"`} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Expected %s to be masked, got %s", secret, output)
+		}
+	}
+	if masked.HTTP.Headers["X-Site"] != "shop1" || config.HTTP.BearerToken != "token" {
+		t.Errorf("Expected only the copy's secrets to be masked, got %s", output)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, name := range []string{"FFPROBE_PATH", "MPLAYER_PATH", "WEBSOCKET_API"} {
+		t.Setenv(name, "")
+	}
+	dir := t.TempDir()
+	tempFile := filepath.Join(dir, "config.json")
+	os.WriteFile(tempFile, []byte(`{
+    "ffmpeg": {"ffprobe": "/missing/ffprobe"},
+    "backend": "mplayr",
+    "audio": {"driver": "puls"},
+    "ws": "http//mmfm",
+    "web": "http://mmfm/song/get",
+    "cache": "`+filepath.ToSlash(filepath.Join(dir, "cache"))+`",
+    "sheduled_audios": [],
+    "scheduled_audios": [{"name": "Closing", "url": "`+filepath.ToSlash(filepath.Join(dir, "closing.mp3"))+`", "schedule": "4pm"}]
+}`), 0644)
+
+	config, err := Load(tempFile)
+	if err != nil {
+		t.Fatal("Load should not return error:", err)
+	}
+	err = config.Validate()
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+
+	expected := map[string]string{
+		"backend":                      `did you mean "mplayer"?`,
+		"audio.driver":                 `did you mean "pulse"?`,
+		"ffmpeg.mplayer":               "",
+		"ffmpeg.ffprobe":               "",
+		"ws":                           "",
+		"sheduled_audios":              `did you mean "scheduled_audios"?`,
+		"scheduled_audios[0].schedule": "",
+		"scheduled_audios[0].url":      "",
+	}
+	for _, problem := range validationErr.Problems {
+		suggestion, ok := expected[problem.Field]
+		if !ok {
+			continue
+		}
+		if suggestion != "" && problem.Suggestion != suggestion {
+			t.Errorf("Expected the suggestion %s for %s, got %s", suggestion, problem.Field, problem.Suggestion)
+		}
+		delete(expected, problem.Field)
+	}
+	if len(expected) > 0 {
+		t.Errorf("Expected problems of %v, got %s", expected, validationErr)
+	}
 }
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the environment variables which set any configuration
+// field, e.g. MMFM_WS or MMFM_SCHEDULED_AUDIOS_0_URL
+const EnvPrefix = "MMFM_"
+
+// loadFromPrefixedEnv sets the fields named by MMFM_ variables. A field is
+// named by the path of its json names in upper case joined by underscores,
+// list entries by their index and map entries by their key in upper case
+// with underscores for dashes, e.g. MMFM_CACHE_OPTIONS_MAX_SIZE,
+// MMFM_SCHEDULED_AUDIOS_0_URL or MMFM_HTTP_HEADERS_X_SITE. Lists of values
+// may also be given comma separated, e.g. MMFM_CACHE_OPTIONS_KEY_PARAMS
+func (c *PlaybackConfig) loadFromPrefixedEnv() error {
+	env := map[string]string{}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, EnvPrefix) {
+			env[name] = value
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	return setFromEnv(reflect.ValueOf(c).Elem(), strings.TrimSuffix(EnvPrefix, "_"), env)
+}
+
+// setFromEnv sets v from the variable name of env, or the fields, entries
+// and elements below it from the variables starting with name
+func setFromEnv(v reflect.Value, name string, env map[string]string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			if _, ok := env[name]; !ok && !hasEnvPrefix(env, name+"_") {
+				return nil
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setFromEnv(v.Elem(), name, env)
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || tag == "" || tag == "-" {
+				continue
+			}
+			if err := setFromEnv(v.Field(i), name+"_"+strings.ToUpper(tag), env); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		if value, ok := env[name]; ok {
+			values := strings.Split(value, ",")
+			list := reflect.MakeSlice(v.Type(), len(values), len(values))
+			for i, value := range values {
+				if err := setValue(list.Index(i), name, strings.TrimSpace(value)); err != nil {
+					return err
+				}
+			}
+			v.Set(list)
+		}
+
+		// Entries by index, the list grows to the highest index
+		last := -1
+		for key := range env {
+			if !strings.HasPrefix(key, name+"_") {
+				continue
+			}
+			index, _, _ := strings.Cut(strings.TrimPrefix(key, name+"_"), "_")
+			if i, err := strconv.Atoi(index); err == nil && i > last {
+				last = i
+			}
+		}
+		if last >= v.Len() {
+			grown := reflect.MakeSlice(v.Type(), last+1, last+1)
+			reflect.Copy(grown, v)
+			v.Set(grown)
+		}
+		for i := 0; i <= last; i++ {
+			if err := setFromEnv(v.Index(i), name+"_"+strconv.Itoa(i), env); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		for key, value := range env {
+			if !strings.HasPrefix(key, name+"_") {
+				continue
+			}
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			entry := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, name+"_"), "_", "-"))
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(elem, key, value); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(entry).Convert(v.Type().Key()), elem)
+		}
+
+	default:
+		if value, ok := env[name]; ok {
+			return setValue(v, name, value)
+		}
+	}
+	return nil
+}
+
+// setValue parses value of the variable name into v
+func setValue(v reflect.Value, name string, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		v.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		v.SetInt(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		v.SetFloat(parsed)
+	default:
+		return fmt.Errorf("%s: unsupported field type %s", name, v.Type())
+	}
+	return nil
+}
+
+// hasEnvPrefix checks if a variable of env starts with prefix
+func hasEnvPrefix(env map[string]string, prefix string) bool {
+	for key := range env {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"strings"
+)
+
+// Configuration file formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// DetectFormat returns the format of a configuration file by its extension,
+// files without a known extension are JSON
+func DetectFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatJSON
+}
+
+// decode parses data of format into v. YAML and TOML documents are
+// converted to JSON first, so the json field names apply to every format
+func decode(format string, data []byte, v interface{}) error {
+	var document map[string]interface{}
+	switch format {
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return err
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &document); err != nil {
+			return err
+		}
+	default:
+		return json.Unmarshal(data, v)
+	}
+
+	converted, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(converted, v)
+}
+
+// encode formats v as format, the field names are the json ones
+func encode(format string, v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil || format == FormatJSON {
+		return data, err
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	switch format {
+	case FormatYAML:
+		encoder := yaml.NewEncoder(&buffer)
+		encoder.SetIndent(2)
+		err = encoder.Encode(document)
+	case FormatTOML:
+		err = toml.NewEncoder(&buffer).Encode(document)
+	default:
+		err = fmt.Errorf("unsupported config format: %s", format)
+	}
+	return buffer.Bytes(), err
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HTTPConfig configures the requests to the web API and the media servers
+type HTTPConfig struct {
+	BearerToken string            `json:"bearer_token,omitempty"` // Sent as Authorization: Bearer
+	Username    string            `json:"username,omitempty"`     // Basic auth, ignored with a bearer token
+	Password    string            `json:"password,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"` // Extra request headers
+	Hosts       []string          `json:"hosts,omitempty"`   // Hosts besides the web API receiving the auth and headers
+	Timeout     string            `json:"timeout,omitempty"` // Playlist request and response header timeout, e.g. 30s
+	Proxy       string            `json:"proxy,omitempty"`   // Proxy URL, defaults to the HTTP_PROXY and HTTPS_PROXY variables
+	CACert      string            `json:"ca_cert,omitempty"` // PEM bundle trusted in addition to the system roots
+	ClientCert  string            `json:"client_cert,omitempty"`
+	ClientKey   string            `json:"client_key,omitempty"`
+}
+
+// RequestTimeout returns the parsed timeout, zero means none
+func (hc *HTTPConfig) RequestTimeout() time.Duration {
+	timeout, err := time.ParseDuration(hc.Timeout)
+	if err != nil || timeout < 0 {
+		return 0
+	}
+	return timeout
+}
+
+// check records the problems of the HTTP options
+func (hc *HTTPConfig) check(ps *problems) {
+	checkDuration(ps, "http.timeout", hc.Timeout)
+	if hc.Proxy != "" {
+		checkURL(ps, "http.proxy", hc.Proxy, "http", "https", "socks5")
+	}
+	for _, host := range hc.Hosts {
+		if host == "" || strings.Contains(host, "/") {
+			ps.add("http.hosts", fmt.Sprintf("%q is not a host name", host), "use a name like media.example.com, with an optional port")
+		}
+	}
+	if (hc.ClientCert == "") != (hc.ClientKey == "") {
+		ps.add("http.client_cert", "http.client_cert and http.client_key must be set together", "set both or neither")
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// variablePattern matches ${VAR} and ${VAR:-default} placeholders
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandVariables replaces the placeholders of value with environment
+// variables. ${VAR:-default} uses default when VAR is unset or empty, the
+// names of unset variables without a default are returned
+func expandVariables(value string) (string, []string) {
+	var unresolved []string
+	expanded := variablePattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+		match := variablePattern.FindStringSubmatch(placeholder)
+		name, hasDefault, fallback := match[1], match[2] != "", match[3]
+		if value, ok := os.LookupEnv(name); ok && (value != "" || !hasDefault) {
+			return value
+		}
+		if hasDefault {
+			return fallback
+		}
+		unresolved = append(unresolved, name)
+		return ""
+	})
+	return expanded, unresolved
+}
+
+// template is a string field holding placeholders
+type template struct {
+	raw      string
+	expanded string
+}
+
+// interpolate expands the placeholders of every string field and remembers
+// the templates, so Save writes them back instead of the expanded values
+func (c *PlaybackConfig) interpolate() error {
+	templates := map[string]template{}
+	missing := map[string]bool{}
+	c.unresolved = nil
+	walkStrings(reflect.ValueOf(c), "", func(path string, value string) string {
+		expanded, unresolved := expandVariables(value)
+		for _, name := range unresolved {
+			missing[name] = true
+			c.unresolved.add(path, "unresolved variable "+name, "set "+name+" or give a default with ${"+name+":-default}")
+		}
+		if expanded != value {
+			templates[path] = template{raw: value, expanded: expanded}
+		}
+		return expanded
+	})
+	c.templates = templates
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unresolved variables: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// restoreTemplates puts the templates back into fields which still hold
+// their expanded value, fields changed since loading keep the new value
+func (c *PlaybackConfig) restoreTemplates() {
+	walkStrings(reflect.ValueOf(c), "", func(path string, value string) string {
+		if template, ok := c.templates[path]; ok && template.expanded == value {
+			return template.raw
+		}
+		return value
+	})
+}
+
+// walkStrings calls fn with the path of json names and the value of every
+// exported string reachable from v, the string is replaced with the result
+// of fn
+func walkStrings(v reflect.Value, path string, fn func(path string, value string) string) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			walkStrings(v.Elem(), path, fn)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				name = field.Name
+			}
+			walkStrings(v.Field(i), joinPath(path, name), fn)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkStrings(v.Index(i), path+"["+strconv.Itoa(i)+"]", fn)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// Map elements are not addressable, they are walked as a copy
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			walkStrings(elem, fmt.Sprintf("%s[%v]", path, key), fn)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(fn(path, v.String()))
+		}
+	}
+}
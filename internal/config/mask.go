@@ -0,0 +1,67 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// mask replaces secrets in the printed configuration
+const mask = "******"
+
+// secretFields are the fields holding credentials
+var secretFields = []string{"bearer_token", "password", "access_key", "secret_key"}
+
+// Masked returns a copy of the configuration with its credentials masked,
+// including secret HTTP headers and passwords in URLs
+func (c *PlaybackConfig) Masked() (*PlaybackConfig, error) {
+	masked, err := c.clone()
+	if err != nil {
+		return nil, err
+	}
+	walkStrings(reflect.ValueOf(masked), "", func(path string, value string) string {
+		if value == "" {
+			return value
+		}
+		if isSecret(path) {
+			return mask
+		}
+		return maskURL(value)
+	})
+	return masked, nil
+}
+
+// isSecret checks if the field at path holds a credential
+func isSecret(path string) bool {
+	for _, field := range secretFields {
+		if strings.HasSuffix(path, "."+field) {
+			return true
+		}
+	}
+
+	if _, header, found := strings.Cut(path, ".headers["); found {
+		header = strings.ToLower(strings.TrimSuffix(header, "]"))
+		for _, secret := range []string{"authorization", "cookie", "token", "key", "secret"} {
+			if strings.Contains(header, secret) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maskURL masks the password of a URL, other values are returned unchanged
+func maskURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); !ok {
+		return value
+	}
+	u.User = url.UserPassword(u.User.Username(), mask)
+	return u.String()
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VolumeSchedule sets the volume during a time of day window
+type VolumeSchedule struct {
+	Start  string `json:"start"`         // Time in HH:MM format
+	End    string `json:"end,omitempty"` // Time in HH:MM format, empty means until midnight
+	Volume int    `json:"volume"`        // Volume (0-100) inside the window
+}
+
+// Contains checks if t falls into the window, windows ending before they
+// start wrap around midnight
+func (vs *VolumeSchedule) Contains(t time.Time) bool {
+	return InWindow(vs.Start, vs.End, t)
+}
+
+// ParseClock parses a time of day in HH:MM format and returns the offset
+// since midnight
+func ParseClock(clock string) (time.Duration, error) {
+	var hour, minute int
+	if len(clock) != 5 || clock[2] != ':' {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", clock)
+	}
+	if _, err := fmt.Sscanf(clock, "%d:%d", &hour, &minute); err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", clock)
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("time of day %q out of range", clock)
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// InWindow checks if t falls between the start and end times of day, an
+// empty end means midnight
+func InWindow(start, end string, t time.Time) bool {
+	from, err := ParseClock(start)
+	if err != nil {
+		return false
+	}
+	to := 24 * time.Hour
+	if end != "" {
+		if to, err = ParseClock(end); err != nil {
+			return false
+		}
+	}
+
+	now := clockOffset(t)
+	if from <= to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to
+}
+
+// clockOffset returns the time of day of t as the offset since midnight
+func clockOffset(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+// NextClock returns the first time after t at the HH:MM time of day
+func NextClock(clock string, t time.Time) (time.Time, error) {
+	offset, err := ParseClock(clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	hour, minute := int(offset/time.Hour), int(offset%time.Hour/time.Minute)
+	next := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, hour, minute, 0, 0, t.Location())
+	}
+	return next, nil
+}
+
+// OperatingHours defines when the player is allowed to play
+type OperatingHours struct {
+	// Weekdays maps lowercase weekday names to comma separated HH:MM-HH:MM
+	// windows, weekdays which are not listed are closed all day
+	Weekdays map[string]string `json:"weekdays"`
+	// Holidays lists closed dates in YYYY-MM-DD format
+	Holidays []string `json:"holidays,omitempty"`
+}
+
+// IsOpen checks if t falls into an operating window. A window ending
+// before it starts continues after midnight, that part belongs to the day
+// the window starts on and to its holidays
+func (oh *OperatingHours) IsOpen(t time.Time) bool {
+	now := clockOffset(t)
+	for _, window := range oh.windows(t) {
+		if window[0] <= now && (now < window[1] || window[0] > window[1]) {
+			return true
+		}
+	}
+	for _, window := range oh.windows(t.AddDate(0, 0, -1)) {
+		if window[0] > window[1] && now < window[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// windows returns the start and end offsets of the windows on the day of
+// t, a holiday has none
+func (oh *OperatingHours) windows(t time.Time) [][2]time.Duration {
+	date := t.Format("2006-01-02")
+	for _, holiday := range oh.Holidays {
+		if holiday == date {
+			return nil
+		}
+	}
+
+	days, ok := oh.Weekdays[strings.ToLower(t.Weekday().String())]
+	if !ok {
+		return nil
+	}
+	windows := [][2]time.Duration{}
+	for _, window := range strings.Split(days, ",") {
+		start, end, err := parseWindow(window)
+		if err != nil {
+			continue
+		}
+		from, _ := ParseClock(start)
+		to, _ := ParseClock(end)
+		windows = append(windows, [2]time.Duration{from, to})
+	}
+	return windows
+}
+
+// validate checks the weekday names, windows and holiday dates
+func (oh *OperatingHours) validate() error {
+	var ps problems
+	oh.check(&ps)
+	return ps.err()
+}
+
+// check records the problems of the weekday names, windows and holiday dates
+func (oh *OperatingHours) check(ps *problems) {
+	weekdays := make([]string, 0, 7)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		weekdays = append(weekdays, strings.ToLower(weekday.String()))
+	}
+
+	days := make([]string, 0, len(oh.Weekdays))
+	for day := range oh.Weekdays {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for _, day := range days {
+		if !isWeekday(day) {
+			ps.add("operating_hours.weekdays."+day, "unknown weekday", suggest(day, weekdays))
+			continue
+		}
+		for _, window := range strings.Split(oh.Weekdays[day], ",") {
+			if _, _, err := parseWindow(window); err != nil {
+				ps.add("operating_hours.weekdays."+day, err.Error(), "use comma separated HH:MM-HH:MM windows, e.g. 09:00-12:00,13:00-21:00")
+			}
+		}
+	}
+	for i, holiday := range oh.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday); err != nil {
+			ps.add(fmt.Sprintf("operating_hours.holidays[%d]", i), fmt.Sprintf("invalid date %q", holiday), "use YYYY-MM-DD, e.g. 2025-12-25")
+		}
+	}
+}
+
+// parseWindow splits a HH:MM-HH:MM window into its start and end
+func parseWindow(window string) (string, string, error) {
+	parts := strings.Split(strings.TrimSpace(window), "-")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", window)
+	}
+	for _, part := range parts {
+		if _, err := ParseClock(part); err != nil {
+			return "", "", err
+		}
+	}
+	return parts[0], parts[1], nil
+}
+
+// isWeekday checks for a lowercase english weekday name
+func isWeekday(day string) bool {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.ToLower(weekday.String()) == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseClock(t *testing.T) {
+	offset, err := ParseClock("08:30")
+	if err != nil {
+		t.Fatal("ParseClock should not return error:", err)
+	}
+	if offset != 8*time.Hour+30*time.Minute {
+		t.Errorf("Expected 8h30m, got %v", offset)
+	}
+
+	for _, clock := range []string{"8:30", "24:00", "12:60", "noon"} {
+		if _, err := ParseClock(clock); err == nil {
+			t.Errorf("Expected error for %q", clock)
+		}
+	}
+}
+
+func TestVolumeScheduleContains(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, _ := time.Parse("15:04", clock)
+		return parsed
+	}
+
+	morning := &VolumeSchedule{Start: "08:00", End: "12:00", Volume: 40}
+	if !morning.Contains(at("08:00")) || !morning.Contains(at("11:59")) {
+		t.Error("Expected morning schedule to contain 08:00 and 11:59")
+	}
+	if morning.Contains(at("12:00")) {
+		t.Error("Expected morning schedule to end at 12:00")
+	}
+
+	evening := &VolumeSchedule{Start: "18:00", Volume: 20}
+	if !evening.Contains(at("23:59")) || evening.Contains(at("07:00")) {
+		t.Error("Expected evening schedule to last until midnight")
+	}
+
+	night := &VolumeSchedule{Start: "22:00", End: "06:00", Volume: 10}
+	if !night.Contains(at("23:00")) || !night.Contains(at("05:00")) || night.Contains(at("12:00")) {
+		t.Error("Expected night schedule to wrap around midnight")
+	}
+}
+
+func TestOperatingHoursIsOpen(t *testing.T) {
+	hours := &OperatingHours{
+		Weekdays: map[string]string{
+			"monday":   "08:00-12:00,13:00-18:00",
+			"friday":   "18:00-02:00",
+			"saturday": "10:00-16:00",
+		},
+		Holidays: []string{"2024-01-08", "2024-01-12"},
+	}
+	at := func(value string) time.Time {
+		parsed, _ := time.Parse("2006-01-02 15:04", value)
+		return parsed
+	}
+
+	// 2024-01-01 is a monday
+	if !hours.IsOpen(at("2024-01-01 09:00")) || !hours.IsOpen(at("2024-01-01 17:59")) {
+		t.Error("Expected monday windows to be open")
+	}
+	if hours.IsOpen(at("2024-01-01 12:30")) {
+		t.Error("Expected lunch break to be closed")
+	}
+	if hours.IsOpen(at("2024-01-02 09:00")) {
+		t.Error("Expected unlisted tuesday to be closed")
+	}
+	if hours.IsOpen(at("2024-01-08 09:00")) {
+		t.Error("Expected holiday to be closed")
+	}
+
+	// 2024-01-05 is a friday, its window continues on saturday
+	if hours.IsOpen(at("2024-01-05 01:00")) || !hours.IsOpen(at("2024-01-05 20:00")) {
+		t.Error("Expected friday to open at 18:00")
+	}
+	if !hours.IsOpen(at("2024-01-06 01:30")) || hours.IsOpen(at("2024-01-06 02:00")) {
+		t.Error("Expected the friday window to end at 02:00 on saturday")
+	}
+	if hours.IsOpen(at("2024-01-13 01:00")) {
+		t.Error("Expected the night after a holiday to be closed")
+	}
+
+	if err := hours.validate(); err != nil {
+		t.Error("validate should not return error:", err)
+	}
+	hours.Weekdays["funday"] = "08:00-18:00"
+	if err := hours.validate(); err == nil {
+		t.Error("Expected error for unknown weekday")
+	}
+}
+
+func TestNextClock(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, _ := time.Parse("2006-01-02 15:04", value)
+		return parsed
+	}
+
+	next, err := NextClock("16:00", at("2024-01-01 09:30"))
+	if err != nil || !next.Equal(at("2024-01-01 16:00")) {
+		t.Errorf("Expected the same day, got %v %v", next, err)
+	}
+	// The current minute has already fired
+	if next, _ := NextClock("16:00", at("2024-01-01 16:00")); !next.Equal(at("2024-01-02 16:00")) {
+		t.Errorf("Expected the next day, got %v", next)
+	}
+	if next, _ := NextClock("08:00", at("2024-12-31 23:00")); !next.Equal(at("2025-01-01 08:00")) {
+		t.Errorf("Expected the next year, got %v", next)
+	}
+	if _, err := NextClock("8am", at("2024-01-01 09:30")); err == nil {
+		t.Error("Expected error for invalid time of day")
+	}
+}
@@ -0,0 +1,398 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Problem is an invalid configuration field
+type Problem struct {
+	Field      string `json:"field"`                // Path of the field, e.g. scheduled_audios[0].schedule
+	Message    string `json:"message"`              // What is wrong
+	Suggestion string `json:"suggestion,omitempty"` // How to fix it
+}
+
+// String formats the problem as "field: message (suggestion)"
+func (p Problem) String() string {
+	if p.Suggestion == "" {
+		return p.Field + ": " + p.Message
+	}
+	return p.Field + ": " + p.Message + " (" + p.Suggestion + ")"
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []Problem
+}
+
+// Error implements error, one problem per line
+func (ve *ValidationError) Error() string {
+	lines := make([]string, len(ve.Problems))
+	for i, problem := range ve.Problems {
+		lines[i] = problem.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// problems collects the problems of a validation pass
+type problems []Problem
+
+// add records a problem of field
+func (ps *problems) add(field string, message string, suggestion string) {
+	*ps = append(*ps, Problem{Field: field, Message: message, Suggestion: suggestion})
+}
+
+// oneOf records a problem when value is not one of the options, an empty
+// value selects the default and is always valid
+func (ps *problems) oneOf(field string, value string, options ...string) {
+	if value == "" {
+		return
+	}
+	for _, option := range options {
+		if value == option {
+			return
+		}
+	}
+	ps.add(field, fmt.Sprintf("unsupported value %q", value), suggest(value, options))
+}
+
+// err returns the problems as a ValidationError, nil without problems
+func (ps problems) err() error {
+	if len(ps) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: ps}
+}
+
+// validate checks the values of the configuration, it is part of loading
+func (c *PlaybackConfig) validate() error {
+	return c.check().err()
+}
+
+// Validate checks the configuration like loading does and also reports
+// unknown fields, binaries which can not be executed, an unwritable cache
+// directory and missing local files
+func (c *PlaybackConfig) Validate() error {
+	ps := c.check()
+	ps = append(ps, c.unknown...)
+	c.checkSystem(&ps)
+	return ps.err()
+}
+
+// check returns every problem of the configuration values
+func (c *PlaybackConfig) check() problems {
+	ps := append(problems{}, c.unresolved...)
+
+	ps.oneOf("backend", c.Backend, BackendMPlayer, BackendFFPlay, BackendNative)
+	if c.Audio != nil {
+		ps.oneOf("audio.driver", c.Audio.Driver, AudioALSA, AudioPulse, AudioPipeWire, AudioWAV, AudioNull)
+		if c.Audio.Device != "" && (c.Audio.Driver == "" || c.Audio.Driver == AudioNull) {
+			ps.add("audio.device", "requires an audio.driver", "set audio.driver to alsa, pulse or pipewire, run the devices command to list the outputs")
+		}
+		if c.Audio.Driver == AudioWAV && c.Audio.Device == "" {
+			ps.add("audio.device", "is required by the wav driver", "set the path of the recorded file")
+		}
+		switch {
+		case c.Backend == BackendFFPlay && c.Audio.Driver == AudioWAV:
+			ps.add("audio.driver", "the ffplay backend can not record wav files", "use the mplayer or native backend")
+		case c.Backend == BackendNative && (c.Audio.Driver == AudioPulse || c.Audio.Driver == AudioPipeWire):
+			ps.add("audio.driver", "the native backend plays to alsa, wav or null", "use an ALSA hw device, run the devices command to list them")
+		}
+	}
+	for _, binary := range c.binaries() {
+		if binary.path == "" {
+			ps.add(binary.field, "is required "+binary.reason, "set "+binary.field+" or "+binary.env)
+		}
+	}
+
+	if c.WebSocketAPI == "" {
+		ps.add("ws", "is required", "set the WebSocket address of the MMFM server, e.g. ws://mmfm.local/io/?EIO=3&transport=websocket")
+	} else {
+		checkURL(&ps, "ws", c.WebSocketAPI, "ws", "wss")
+	}
+	ps.oneOf("playlist_type", c.PlaylistType, "json", "m3u", "dir", "feed")
+	if c.WebAPI == "" {
+		ps.add("web", "is required", "set the playlist API, e.g. http://mmfm.local/song/get")
+	} else if c.PlaylistType == "" || c.PlaylistType == "json" {
+		if strings.Contains(c.WebAPI, "://") {
+			checkURL(&ps, "web", c.WebAPI, "http", "https")
+		}
+	}
+	checkDuration(&ps, "playlist_poll", c.PlaylistPoll)
+	if c.CachePath == "" {
+		ps.add("cache", "is required", "set a writable directory, e.g. /var/cache/mmfm")
+	}
+
+	if c.CacheOptions != nil {
+		c.CacheOptions.check(&ps)
+	}
+	if c.HTTP != nil {
+		c.HTTP.check(&ps)
+	}
+
+	if c.Crossfade < 0 {
+		ps.add("crossfade", fmt.Sprintf("must not be negative, got %v", c.Crossfade), "use 0 to disable the crossfade")
+	}
+	if c.Crossfade > 0 && c.Backend == BackendNative {
+		// Both songs of a crossfade would open the same device
+		ps.add("crossfade", "is not supported by the native backend", "use the mplayer backend or set crossfade to 0")
+	}
+	if c.VolumeRamp < 0 {
+		ps.add("volume_ramp", fmt.Sprintf("must not be negative, got %v", c.VolumeRamp), "use 0 to change the volume at once")
+	}
+
+	for i, audio := range c.ScheduledAudios {
+		field := fmt.Sprintf("scheduled_audios[%d]", i)
+		if audio.URL == "" {
+			ps.add(field+".url", "is required", "set the URL or local path of the audio")
+		} else if strings.Contains(audio.URL, "://") {
+			checkURL(&ps, field+".url", audio.URL, "http", "https", "file")
+		}
+		if _, err := ParseClock(audio.Schedule); err != nil {
+			ps.add(field+".schedule", err.Error(), "use the time of day in HH:MM format, e.g. 16:00")
+		}
+	}
+
+	for i, schedule := range c.VolumeSchedules {
+		field := fmt.Sprintf("volume_schedules[%d]", i)
+		if _, err := ParseClock(schedule.Start); err != nil {
+			ps.add(field+".start", err.Error(), "use HH:MM, e.g. 08:00")
+		}
+		if schedule.End != "" {
+			if _, err := ParseClock(schedule.End); err != nil {
+				ps.add(field+".end", err.Error(), "use HH:MM, or leave it empty for midnight")
+			}
+		}
+		if schedule.Volume < 0 || schedule.Volume > 100 {
+			ps.add(field+".volume", fmt.Sprintf("must be between 0 and 100, got %d", schedule.Volume), "")
+		}
+	}
+
+	if c.OperatingHours != nil {
+		c.OperatingHours.check(&ps)
+	}
+	ps.oneOf("resume", c.Resume, ResumePosition, ResumeSong, ResumeOff)
+	return ps
+}
+
+// binary is an external program required by the configuration
+type binary struct {
+	field  string
+	env    string
+	path   string
+	reason string
+}
+
+// binaries returns the programs the configured features run
+func (c *PlaybackConfig) binaries() []binary {
+	// The native backend decodes the songs and reads their durations itself
+	binaries := []binary{}
+	switch c.Backend {
+	case BackendNative:
+	case BackendFFPlay:
+		binaries = append(binaries,
+			binary{"ffmpeg.ffprobe", "FFPROBE_PATH", c.FFMpegConf.FFProbe, "to read the song durations"},
+			binary{"ffmpeg.ffplay", "FFPLAY_PATH", c.FFMpegConf.FFPlay, "by the ffplay backend"})
+	default:
+		binaries = append(binaries,
+			binary{"ffmpeg.ffprobe", "FFPROBE_PATH", c.FFMpegConf.FFProbe, "to read the song durations"},
+			binary{"ffmpeg.mplayer", "MPLAYER_PATH", c.FFMpegConf.MPlayer, "by the mplayer backend"})
+	}
+	if c.Loudness != nil {
+		binaries = append(binaries, binary{"ffmpeg.ffmpeg", "FFMPEG_PATH", c.FFMpegConf.FFMpeg, "by the loudness normalization"})
+	}
+	return binaries
+}
+
+// checkSystem checks the configuration against the machine it runs on
+func (c *PlaybackConfig) checkSystem(ps *problems) {
+	for _, binary := range c.binaries() {
+		if binary.path == "" {
+			continue
+		}
+		if _, err := exec.LookPath(binary.path); err != nil {
+			ps.add(binary.field, fmt.Sprintf("%s is not an executable", binary.path), "install it or set "+binary.field+" or "+binary.env+" to its path")
+		}
+	}
+
+	if c.CachePath != "" {
+		if err := checkWritable(c.CachePath); err != nil {
+			ps.add("cache", fmt.Sprintf("%s is not writable: %v", c.CachePath, err), "create the directory and grant the player write access")
+		}
+	}
+
+	for i, audio := range c.ScheduledAudios {
+		checkFile(ps, fmt.Sprintf("scheduled_audios[%d].url", i), audio.URL)
+	}
+	checkFile(ps, "playlist_fallback", c.PlaylistFallback)
+	if c.PlaylistType == "dir" || c.PlaylistType == "m3u" {
+		checkFile(ps, "web", c.WebAPI)
+	}
+	if c.HTTP != nil {
+		checkFile(ps, "http.ca_cert", c.HTTP.CACert)
+		checkFile(ps, "http.client_cert", c.HTTP.ClientCert)
+		checkFile(ps, "http.client_key", c.HTTP.ClientKey)
+	}
+}
+
+// checkWritable creates and removes a file in dir
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".validate-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// checkFile records a problem when the local path location does not exist,
+// remote locations are not checked
+func checkFile(ps *problems, field string, location string) {
+	if location == "" || (strings.Contains(location, "://") && !strings.HasPrefix(location, "file://")) {
+		return
+	}
+	path := strings.TrimPrefix(location, "file://")
+	if _, err := os.Stat(path); err != nil {
+		ps.add(field, fmt.Sprintf("%s does not exist", path), "check the path or copy the file onto the player")
+	}
+}
+
+// checkURL records a problem when value is not an absolute URL of one of
+// the schemes
+func checkURL(ps *problems, field string, value string, schemes ...string) {
+	u, err := url.Parse(value)
+	if err != nil {
+		ps.add(field, fmt.Sprintf("invalid URL: %v", err), "")
+		return
+	}
+	if u.Host == "" && u.Scheme != "file" {
+		ps.add(field, fmt.Sprintf("%q has no host", value), "use an absolute URL such as "+schemes[0]+"://host/path")
+		return
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return
+		}
+	}
+	ps.add(field, fmt.Sprintf("unsupported scheme %q", u.Scheme), suggest(u.Scheme, schemes))
+}
+
+// checkDuration records a problem when value is not a duration
+func checkDuration(ps *problems, field string, value string) {
+	if value == "" {
+		return
+	}
+	if duration, err := time.ParseDuration(value); err != nil || duration < 0 {
+		ps.add(field, fmt.Sprintf("invalid duration %q", value), "use a positive duration such as 30s, 10m or 1h")
+	}
+}
+
+// checkSize records a problem when value is not a size
+func checkSize(ps *problems, field string, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := ParseSize(value); err != nil {
+		ps.add(field, err.Error(), "use a size such as 500MB or 2GB")
+	}
+}
+
+// suggest proposes the option closest to a misspelled value
+func suggest(value string, options []string) string {
+	best, distance := "", -1
+	for _, option := range options {
+		if d := editDistance(strings.ToLower(value), option); distance < 0 || d < distance {
+			best, distance = option, d
+		}
+	}
+	if best != "" && distance <= len(best)/2 {
+		return fmt.Sprintf("did you mean %q?", best)
+	}
+	return "use one of " + strings.Join(options, ", ")
+}
+
+// editDistance returns the Levenshtein distance of a and b
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// unknownFields records the keys of a decoded document which no field of t
+// is named after, e.g. misspelled ones
+func unknownFields(ps *problems, document interface{}, t reflect.Type, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := document.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		names := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if field.IsExported() && name != "" && name != "-" {
+				fields[name] = field.Type
+				names = append(names, name)
+			}
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if fieldType, ok := fields[key]; ok {
+				unknownFields(ps, object[key], fieldType, joinPath(path, key))
+				continue
+			}
+			ps.add(joinPath(path, key), "unknown field", suggest(key, names))
+		}
+
+	case reflect.Slice:
+		list, ok := document.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range list {
+			unknownFields(ps, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// joinPath appends the field name to path
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
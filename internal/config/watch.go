@@ -0,0 +1,80 @@
+package config
+
+import (
+	"mmfm-playback-go/internal/logger"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// watchInterval is how often the configuration file is checked for changes
+const watchInterval = 5 * time.Second
+
+// Watcher reloads the configuration file when it changes or the process
+// receives SIGHUP, invalid configurations are rejected
+type Watcher struct {
+	filename string
+	interval time.Duration
+	onChange func(*PlaybackConfig)
+	modTime  time.Time
+	size     int64
+}
+
+// NewWatcher creates a new Watcher passing every valid reload to onChange
+func NewWatcher(filename string, onChange func(*PlaybackConfig)) *Watcher {
+	w := &Watcher{
+		filename: filename,
+		interval: watchInterval,
+		onChange: onChange,
+	}
+	w.changed()
+	return w
+}
+
+// Start watches in background
+func (w *Watcher) Start() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hangup:
+				logger.Logger.Info("SIGHUP received, reloading", w.filename)
+				w.changed()
+				w.reload()
+			case <-ticker.C:
+				if w.changed() {
+					logger.Logger.Info("configuration file changed, reloading", w.filename)
+					w.reload()
+				}
+			}
+		}
+	}()
+}
+
+// changed records the modification time and size of the file and reports
+// whether they differ from the previous check
+func (w *Watcher) changed() bool {
+	stat, err := os.Stat(w.filename)
+	if err != nil {
+		return false
+	}
+	changed := !stat.ModTime().Equal(w.modTime) || stat.Size() != w.size
+	w.modTime = stat.ModTime()
+	w.size = stat.Size()
+	return changed
+}
+
+// reload passes the configuration file to onChange if it is valid
+func (w *Watcher) reload() {
+	conf, err := Reload(w.filename)
+	if err != nil {
+		logger.Logger.Error("invalid configuration, keeping the current one:", err)
+		return
+	}
+	w.onChange(conf)
+}
@@ -0,0 +1,227 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/config"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// presignedParams are query parameters of signed URLs, such URLs carry their
+// own authorization and are refused by some stores when another one is sent
+var presignedParams = []string{"X-Amz-Signature", "X-Amz-Credential", "X-Goog-Signature", "X-Goog-Credential", "AWSAccessKeyId", "sig"}
+
+// Client sends the requests to the web API and the media servers, the
+// configured authentication and headers are only sent to trusted hosts
+type Client struct {
+	client  *http.Client
+	header  http.Header
+	hosts   []string
+	timeout time.Duration
+}
+
+// Default is a client without authentication
+var Default = &Client{client: http.DefaultClient, header: http.Header{}}
+
+// New creates a new Client from the configuration, the host of webAPI and the
+// configured hosts are trusted with the headers. A nil configuration returns
+// Default
+func New(conf *config.HTTPConfig, webAPI string) (*Client, error) {
+	if conf == nil {
+		return Default, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = conf.RequestTimeout()
+	if conf.Proxy != "" {
+		proxy, err := url.Parse(conf.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsConfig, err := newTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	header := http.Header{}
+	for name, value := range conf.Headers {
+		header.Set(name, value)
+	}
+	if conf.BearerToken != "" {
+		header.Set("Authorization", "Bearer "+conf.BearerToken)
+	} else if conf.Username != "" {
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(conf.Username, conf.Password)
+		header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+
+	hosts := append([]string{}, conf.Hosts...)
+	if api, err := url.Parse(webAPI); err == nil && api.Host != "" {
+		hosts = append(hosts, api.Host)
+	}
+
+	c := &Client{
+		header:  header,
+		hosts:   hosts,
+		timeout: conf.RequestTimeout(),
+	}
+	c.client = &http.Client{Transport: transport, CheckRedirect: c.checkRedirect}
+	return c, nil
+}
+
+// checkRedirect removes the configured headers from a redirect to a host
+// which is not trusted, net/http only removes Authorization and Cookie
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !c.Trusts(req.URL) {
+		for name := range c.header {
+			req.Header.Del(name)
+		}
+	}
+	return nil
+}
+
+// newTLSConfig loads the CA bundle and the client certificate
+func newTLSConfig(conf *config.HTTPConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if conf.CACert != "" {
+		pem, err := os.ReadFile(conf.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", conf.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if conf.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(conf.ClientCert, conf.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Do sends req with the configured headers if its host is trusted, headers
+// already set on req are kept
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.Trusts(req.URL) {
+		for name, values := range c.header {
+			if req.Header.Get(name) == "" {
+				req.Header[name] = values
+			}
+		}
+	}
+	return c.client.Do(req)
+}
+
+// Trusts checks if the headers are sent to u. Hosts match by name, loopback
+// hosts only with the same port so local relays never receive them, and
+// presigned URLs are sent as they are
+func (c *Client) Trusts(u *url.URL) bool {
+	if len(c.header) == 0 || isPresigned(u) {
+		return false
+	}
+	for _, host := range c.hosts {
+		if isLoopback(u.Hostname()) {
+			if strings.EqualFold(host, u.Host) {
+				return true
+			}
+			continue
+		}
+		name := host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			name = hostname
+		}
+		if strings.EqualFold(name, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPresigned checks if u carries the signature of an object store
+func isPresigned(u *url.URL) bool {
+	query := u.Query()
+	for _, param := range presignedParams {
+		if query.Has(param) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopback checks if host names the local machine
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Get requests url within the configured timeout, the whole response body
+// must be read before it expires
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	var cancel context.CancelFunc
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		if cancel != nil {
+			cancel()
+		}
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		if cancel != nil {
+			cancel()
+		}
+		return nil, err
+	}
+	if cancel != nil {
+		resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	}
+	return resp, nil
+}
+
+// Timeout returns the configured request timeout, zero means none
+func (c *Client) Timeout() time.Duration {
+	return c.timeout
+}
+
+// cancelBody releases the timeout of a request once its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer
+func (cb *cancelBody) Close() error {
+	err := cb.ReadCloser.Close()
+	cb.cancel()
+	return err
+}
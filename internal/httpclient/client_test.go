@@ -0,0 +1,169 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientHeaders(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer server.Close()
+
+	client, err := New(&config.HTTPConfig{
+		BearerToken: "secret",
+		Headers:     map[string]string{"X-Device": "shop-1"},
+	}, server.URL+"/song/get")
+	if err != nil {
+		t.Fatal("New should not return error:", err)
+	}
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal("Get should not return error:", err)
+	}
+	resp.Body.Close()
+
+	if header.Get("Authorization") != "Bearer secret" || header.Get("X-Device") != "shop-1" {
+		t.Errorf("Expected the configured headers, got %v", header)
+	}
+
+	// Presigned urls carry their own authorization
+	resp, _ = client.Get(context.Background(), server.URL+"/song.mp3?X-Amz-Signature=abc")
+	resp.Body.Close()
+	if header.Get("Authorization") != "" {
+		t.Errorf("Expected no headers for a presigned url, got %v", header)
+	}
+
+	// Loopback hosts need the same port, other hosts the same name
+	other, _ := url.Parse("http://127.0.0.1:1/stream/song")
+	if client.Trusts(other) {
+		t.Error("Expected another loopback port not to be trusted")
+	}
+	client, _ = New(&config.HTTPConfig{Username: "user", Hosts: []string{"media.example.com"}}, "https://mmfm.example.com/song/get")
+	for _, location := range []string{"https://mmfm.example.com:8443/song.mp3", "http://media.example.com/song.mp3"} {
+		if u, _ := url.Parse(location); !client.Trusts(u) {
+			t.Errorf("Expected %s to be trusted", location)
+		}
+	}
+	if u, _ := url.Parse("https://cdn.example.com/song.mp3"); client.Trusts(u) {
+		t.Error("Expected another host not to be trusted")
+	}
+}
+
+func TestClientRedirect(t *testing.T) {
+	var header http.Header
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.RedirectHandler(other.URL+"/song.mp3", http.StatusFound))
+	defer server.Close()
+
+	client, _ := New(&config.HTTPConfig{Headers: map[string]string{"X-Api-Key": "secret"}}, server.URL)
+	resp, err := client.Get(context.Background(), server.URL+"/song.mp3")
+	if err != nil {
+		t.Fatal("Get should not return error:", err)
+	}
+	resp.Body.Close()
+	if header == nil || header.Get("X-Api-Key") != "" {
+		t.Errorf("Expected the redirect to another host without the headers, got %v", header)
+	}
+}
+
+func TestClientRelay(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		http.ServeContent(w, r, "song.mp3", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer server.Close()
+
+	client, _ := New(&config.HTTPConfig{BearerToken: "secret"}, server.URL)
+	local, err := client.Local(server.URL + "/song.mp3")
+	if err != nil || local == server.URL+"/song.mp3" {
+		t.Fatalf("Expected a relayed url, got %s %v", local, err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, local, nil)
+	req.Header.Set("Range", "bytes=4-")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Relay should not return error:", err)
+	}
+	content, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(content) != "456789" {
+		t.Errorf("Expected the relayed range, got %d %q", resp.StatusCode, content)
+	}
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Expected the relay to add the headers, got %v", header)
+	}
+
+	if direct, _ := Default.Local(server.URL); direct != server.URL {
+		t.Errorf("Expected urls without headers to be returned as they are, got %s", direct)
+	}
+
+	// Only the recently used urls are kept
+	for i := 0; i < maxRelayTargets; i++ {
+		client.Local(fmt.Sprintf("%s/%d.mp3", server.URL, i))
+	}
+	resp, err = http.Get(local)
+	if err != nil {
+		t.Fatal("Relay should not return error:", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the oldest url to be dropped, got %d", resp.StatusCode)
+	}
+	if len(localRelay.targets) > maxRelayTargets {
+		t.Errorf("Expected at most %d relayed urls, got %d", maxRelayTargets, len(localRelay.targets))
+	}
+}
+
+func TestClientCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	os.WriteFile(caFile, content, 0644)
+
+	if _, err := Default.Get(context.Background(), server.URL); err == nil {
+		t.Error("Expected the test certificate to be rejected without the CA bundle")
+	}
+
+	client, err := New(&config.HTTPConfig{CACert: caFile}, "")
+	if err != nil {
+		t.Fatal("New should not return error:", err)
+	}
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal("Get should trust the CA bundle:", err)
+	}
+	resp.Body.Close()
+}
+
+func TestClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client, _ := New(&config.HTTPConfig{Timeout: "50ms"}, "")
+	if _, err := client.Get(context.Background(), server.URL); err == nil {
+		t.Error("Expected the request to time out")
+	}
+}
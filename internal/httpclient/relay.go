@@ -0,0 +1,141 @@
+package httpclient
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/logger"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// relayPrefix is the path of the relayed URLs
+const relayPrefix = "/relay/"
+
+// relayedRequestHeaders are passed from the external program to the origin
+var relayedRequestHeaders = []string{"Range", "If-Range", "Icy-MetaData"}
+
+// relayedResponseHeaders are passed from the origin to the external program
+var relayedResponseHeaders = []string{
+	"Accept-Ranges", "Content-Length", "Content-Range", "Content-Type", "ETag", "Last-Modified",
+	"Icy-Metaint", "Icy-Name",
+}
+
+// maxRelayTargets is the count of URLs the relay keeps, the least recently
+// used one is dropped beyond it
+const maxRelayTargets = 64
+
+// relayTarget is a URL requested with the client which relays it
+type relayTarget struct {
+	client *Client
+	url    string
+	used   time.Time
+}
+
+// relay serves remote URLs to external programs on the loopback interface,
+// the programs never see the headers which are added by the client instead
+type relay struct {
+	addr    string
+	targets map[string]relayTarget
+	lock    sync.Mutex
+}
+
+// localRelay is shared by all clients, it is started on first use
+var localRelay = &relay{targets: make(map[string]relayTarget)}
+
+// Local returns the URL external programs read rawURL from, trusted URLs
+// are relayed on the loopback interface so the headers do not appear on
+// command lines, other URLs are returned as they are
+func (c *Client) Local(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !c.Trusts(u) {
+		return rawURL, nil
+	}
+	return localRelay.url(c, rawURL)
+}
+
+// url registers rawURL and returns its local URL
+func (r *relay) url(client *Client, rawURL string) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.addr == "" {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		r.addr = listener.Addr().String()
+		go func() {
+			err := http.Serve(listener, http.HandlerFunc(r.serve))
+			logger.Logger.Error(err)
+		}()
+		logger.Logger.Info("authenticated relay listening on", r.addr)
+	}
+
+	sum := sha1.Sum([]byte(rawURL))
+	id := hex.EncodeToString(sum[:])
+	r.targets[id] = relayTarget{client: client, url: rawURL, used: time.Now()}
+	if len(r.targets) > maxRelayTargets {
+		r.dropOldest()
+	}
+	return fmt.Sprintf("http://%s%s%s", r.addr, relayPrefix, id), nil
+}
+
+// dropOldest removes the least recently used target, r.lock is held
+func (r *relay) dropOldest() {
+	var oldest string
+	for id, target := range r.targets {
+		if oldest == "" || target.used.Before(r.targets[oldest].used) {
+			oldest = id
+		}
+	}
+	delete(r.targets, oldest)
+}
+
+// serve requests the target of the path with its client and copies the
+// response, the range requests of seeking programs are passed on
+func (r *relay) serve(w http.ResponseWriter, req *http.Request) {
+	id := strings.TrimPrefix(req.URL.Path, relayPrefix)
+	r.lock.Lock()
+	target, ok := r.targets[id]
+	if ok {
+		target.used = time.Now()
+		r.targets[id] = target
+	}
+	r.lock.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	upstream, err := http.NewRequestWithContext(req.Context(), req.Method, target.url, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	for _, name := range relayedRequestHeaders {
+		if value := req.Header.Get(name); value != "" {
+			upstream.Header.Set(name, value)
+		}
+	}
+	resp, err := target.client.Do(upstream)
+	if err != nil {
+		logger.Logger.Error(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, name := range relayedResponseHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
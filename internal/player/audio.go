@@ -0,0 +1,232 @@
+package player
+
+import (
+	"fmt"
+	"mmfm-playback-go/internal/config"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// AudioDevice is an audio output the backends can play to
+type AudioDevice struct {
+	Driver      string `json:"driver"`
+	Device      string `json:"device,omitempty"`
+	Description string `json:"description"`
+}
+
+// Spec returns the driver:device form accepted by player.output
+func (ad AudioDevice) Spec() string {
+	if ad.Device == "" {
+		return ad.Driver
+	}
+	return ad.Driver + ":" + ad.Device
+}
+
+// ListAudioDevices enumerates the ALSA playback devices and the PulseAudio
+// or PipeWire sinks of the machine, the null output is always available
+func ListAudioDevices() []AudioDevice {
+	devices := []AudioDevice{}
+	cards, cardsErr := os.ReadFile("/proc/asound/cards")
+	pcm, pcmErr := os.ReadFile("/proc/asound/pcm")
+	if cardsErr == nil && pcmErr == nil {
+		devices = append(devices, parseALSADevices(string(cards), string(pcm))...)
+	}
+	if output, err := exec.Command("pactl", "list", "short", "sinks").Output(); err == nil {
+		devices = append(devices, parsePulseSinks(string(output))...)
+	}
+	return append(devices, AudioDevice{Driver: config.AudioNull, Description: "discards the audio, for tests"})
+}
+
+// alsaCardPattern matches a card of /proc/asound/cards, e.g.
+// " 1 [Device         ]: USB-Audio - USB Audio Device"
+var alsaCardPattern = regexp.MustCompile(`^\s*(\d+)\s+\[(\S+)\s*\]:\s*(.*)$`)
+
+// parseALSADevices returns the playback devices of /proc/asound/pcm. They
+// are named by card id, which unlike the card number survives OS updates
+// and plugging in other cards
+func parseALSADevices(cards string, pcm string) []AudioDevice {
+	ids := map[string]string{}
+	names := map[string]string{}
+	for _, line := range strings.Split(cards, "\n") {
+		if match := alsaCardPattern.FindStringSubmatch(line); match != nil {
+			ids[match[1]] = match[2]
+			if _, name, found := strings.Cut(match[3], " - "); found {
+				names[match[1]] = strings.TrimSpace(name)
+			} else {
+				names[match[1]] = strings.TrimSpace(match[3])
+			}
+		}
+	}
+
+	devices := []AudioDevice{}
+	for _, line := range strings.Split(pcm, "\n") {
+		// 01-00: USB Audio : USB Audio : playback 1 : capture 1
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 || !strings.Contains(line, "playback") {
+			continue
+		}
+		card, device, found := strings.Cut(strings.TrimSpace(fields[0]), "-")
+		if !found {
+			continue
+		}
+		card, device = strings.TrimLeft(card, "0"), strings.TrimLeft(device, "0")
+		if card == "" {
+			card = "0"
+		}
+		if device == "" {
+			device = "0"
+		}
+		id, ok := ids[card]
+		if !ok {
+			continue
+		}
+		devices = append(devices, AudioDevice{
+			Driver:      config.AudioALSA,
+			Device:      fmt.Sprintf("hw:CARD=%s,DEV=%s", id, device),
+			Description: fmt.Sprintf("%s, %s (hw:%s,%s)", names[card], strings.TrimSpace(fields[1]), card, device),
+		})
+	}
+	return devices
+}
+
+// parsePulseSinks returns the sinks of "pactl list short sinks", PipeWire
+// lists them as well through pipewire-pulse
+func parsePulseSinks(output string) []AudioDevice {
+	devices := []AudioDevice{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		description := "sink " + fields[0]
+		if len(fields) > 4 {
+			description += ", " + strings.ToLower(fields[4])
+		}
+		devices = append(devices, AudioDevice{Driver: config.AudioPulse, Device: fields[1], Description: description})
+	}
+	return devices
+}
+
+// mplayerOutput returns the -ao option of mplayer for an output, empty for
+// the default one. The device is escaped with the %length% syntax of mplayer
+// suboptions as ALSA names contain colons and commas. PipeWire is reached
+// through its PulseAudio server, the wav device is the recorded file
+func mplayerOutput(driver string, device string) string {
+	escaped := fmt.Sprintf("%%%d%%%s", len(device), device)
+	switch driver {
+	case config.AudioNull:
+		return "null"
+	case config.AudioALSA:
+		if device == "" {
+			return "alsa"
+		}
+		return "alsa:device=" + escaped
+	case config.AudioPulse, config.AudioPipeWire:
+		if device == "" {
+			return "pulse"
+		}
+		return "pulse::" + escaped
+	case config.AudioWAV:
+		return "pcm:file=" + escaped
+	}
+	return ""
+}
+
+// sdlEnv returns the environment selecting an output for the SDL audio of
+// ffplay, it can not record wav files
+func sdlEnv(driver string, device string) []string {
+	env := []string{}
+	switch driver {
+	case config.AudioNull:
+		return append(env, "SDL_AUDIODRIVER=dummy")
+	case config.AudioALSA:
+		env = append(env, "SDL_AUDIODRIVER=alsa")
+		if device != "" {
+			env = append(env, "AUDIODEV="+device)
+		}
+	case config.AudioPulse:
+		env = append(env, "SDL_AUDIODRIVER=pulseaudio")
+		if device != "" {
+			env = append(env, "PULSE_SINK="+device)
+		}
+	case config.AudioPipeWire:
+		env = append(env, "SDL_AUDIODRIVER=pipewire")
+		if device != "" {
+			env = append(env, "PIPEWIRE_NODE="+device)
+		}
+	}
+	return env
+}
+
+// parseOutput parses the output param of player.output, either an object
+// with driver and device or a driver:device string such as alsa:hw:1,0. A
+// string without a known driver is a device of the current driver
+func parseOutput(param interface{}, current config.AudioConfig) (config.AudioConfig, error) {
+	switch value := param.(type) {
+	case map[string]interface{}:
+		driver, _ := value["driver"].(string)
+		device, _ := value["device"].(string)
+		return checkOutput(config.AudioConfig{Driver: driver, Device: device})
+	case string:
+		value = strings.TrimSpace(value)
+		driver, device, _ := strings.Cut(value, ":")
+		if isDriver(driver) {
+			return checkOutput(config.AudioConfig{Driver: driver, Device: device})
+		}
+		return checkOutput(config.AudioConfig{Driver: current.Driver, Device: value})
+	}
+	return config.AudioConfig{}, fmt.Errorf("invalid output %v", param)
+}
+
+// checkOutput rejects outputs the backends can not select
+func checkOutput(output config.AudioConfig) (config.AudioConfig, error) {
+	if output.Driver != "" && !isDriver(output.Driver) {
+		return output, fmt.Errorf("unsupported audio driver %q", output.Driver)
+	}
+	if output.Device != "" && output.Driver == "" {
+		return output, fmt.Errorf("audio device %q requires a driver", output.Device)
+	}
+	return output, nil
+}
+
+// isDriver checks for a supported audio driver
+func isDriver(driver string) bool {
+	switch driver {
+	case config.AudioALSA, config.AudioPulse, config.AudioPipeWire, config.AudioWAV, config.AudioNull:
+		return true
+	}
+	return false
+}
+
+// SetOutput switches the audio output of the backends, a playing song
+// continues on the new output from its position
+func (mp *MusicPlayer) SetOutput(output config.AudioConfig) {
+	Logger.Infof("audio output %s %s", output.Driver, output.Device)
+	mp.lock.Lock()
+	mp.output = output
+	for _, backend := range []Backend{mp.player, mp.fader, mp.nextPlayer, mp.nextFader} {
+		if backend != nil {
+			backend.SetOutput(output.Driver, output.Device)
+		}
+	}
+	song := mp.currentSong
+	idle := mp.pauseFlag || song == nil || mp.scheduledAudioPlaying
+	var second int
+	if !idle {
+		second = int(song.Index)
+	}
+	mp.lock.Unlock()
+
+	if idle {
+		return
+	}
+	mp.stopPlayback()
+	go func() {
+		if err := mp.Play(song, second); err != nil {
+			Logger.Error(err)
+			mp.Next()
+		}
+	}()
+}
@@ -0,0 +1,77 @@
+package player
+
+import (
+	"mmfm-playback-go/internal/config"
+	"mmfm-playback-go/internal/httpclient"
+	"strings"
+)
+
+// Backend renders audio for the MusicPlayer, in an external process or in
+// process for the native backend
+type Backend interface {
+	// Play starts the media at url from the given second, the returned
+	// channel receives nil once playback has ended or has been stopped, and
+	// the error, with the end of stderr for processes, when playback failed
+	Play(url string, second int) (<-chan error, error)
+	// Stop terminates the current playback
+	Stop() error
+	// SetVolume changes the output volume (0-100), it applies to the running
+	// playback as well as to the next Play
+	SetVolume(volume int) error
+	// SetGain sets the gain in dB applied to the next Play, it is used for
+	// loudness normalization
+	SetGain(gain float64)
+	// SetClient sets the HTTP client requesting remote urls
+	SetClient(client *httpclient.Client)
+	// SetOutput selects the audio driver and device of the next Play, empty
+	// values select the default output
+	SetOutput(driver string, device string)
+}
+
+// isRemote checks if url is fetched over HTTP
+func isRemote(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// localURL returns the url an external program reads url from, remote urls
+// requested with the headers of client go through its local relay so the
+// headers stay off the command line
+func localURL(client *httpclient.Client, url string) (string, error) {
+	if client == nil || !isRemote(url) {
+		return url, nil
+	}
+	return client.Local(url)
+}
+
+// NewBackend creates the backend selected in the configuration
+func NewBackend(conf *config.PlaybackConfig) Backend {
+	var backend Backend
+	switch conf.Backend {
+	case config.BackendFFPlay:
+		backend = NewFFplay(conf.FFMpegConf.FFPlay)
+	case config.BackendNative:
+		backend = NewNative()
+	default:
+		backend = NewMplayer(conf.FFMpegConf.MPlayer)
+	}
+	if conf.Audio != nil {
+		backend.SetOutput(conf.Audio.Driver, conf.Audio.Device)
+	}
+	return backend
+}
+
+// Prober reads the format and the duration of songs
+type Prober interface {
+	GetMediaInfo(url string) (*MediaInfo, error)
+	// SetClient sets the HTTP client requesting remote urls
+	SetClient(client *httpclient.Client)
+}
+
+// NewProber creates the prober of the configured backend, the native
+// backend reads the songs itself instead of running ffprobe
+func NewProber(conf *config.PlaybackConfig) Prober {
+	if conf.Backend == config.BackendNative {
+		return NewNativeProbe()
+	}
+	return NewFFprobe(conf.FFMpegConf.FFProbe)
+}
@@ -0,0 +1,149 @@
+package player
+
+import (
+	"mmfm-playback-go/internal/config"
+	"mmfm-playback-go/pkg/types"
+	"time"
+)
+
+// fadeStep is the interval between two volume changes of a crossfade
+const fadeStep = 100 * time.Millisecond
+
+// crossfadeDuration returns how long a song overlaps with the next one,
+// zero disables the crossfade for the song. ffplay can not change the volume
+// while playing and both songs of the native backend would open the same
+// device, so they do not crossfade
+func (mp *MusicPlayer) crossfadeDuration(duration float64) time.Duration {
+	conf := mp.config()
+	fade := conf.Crossfade
+	if fade <= 0 || duration < fade*2 || conf.Backend == config.BackendFFPlay || conf.Backend == config.BackendNative {
+		return 0
+	}
+	return time.Duration(fade * float64(time.Second))
+}
+
+// scheduleCrossfade arms the crossfade into the next song of the playlist
+func (mp *MusicPlayer) scheduleCrossfade(song *types.Song, second int) {
+	fade := mp.crossfadeDuration(song.Duration)
+	mp.lock.Lock()
+	scheduled := mp.scheduledAudioPlaying
+	mp.lock.Unlock()
+	if fade <= 0 || scheduled {
+		return
+	}
+	remaining := time.Duration((song.Duration - float64(second)) * float64(time.Second))
+	if remaining <= fade {
+		return
+	}
+
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	generation := mp.generation
+	mp.fadeTimer = time.AfterFunc(remaining-fade, func() {
+		mp.crossfade(fade, generation)
+	})
+}
+
+// cancelCrossfade disarms a pending crossfade
+func (mp *MusicPlayer) cancelCrossfade() {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	if mp.fadeTimer != nil {
+		mp.fadeTimer.Stop()
+		mp.fadeTimer = nil
+	}
+}
+
+// crossfade starts the next song on the idle backend and ramps the volumes
+// of both backends, it falls back to a plain transition when the next song
+// can not be faded in. The playback of generation is the one fading out,
+// nothing is swapped once another playback took over
+func (mp *MusicPlayer) crossfade(fade time.Duration, generation int) {
+	mp.lock.Lock()
+	// Reloaded backends take over with the plain transition
+	skip := mp.pauseFlag || mp.scheduledAudioPlaying || mp.nextPlayer != nil || mp.generation != generation || len(mp.playlist) == 0
+	var index float64
+	var song *types.Song
+	if !skip {
+		index = mp.currentIndex + 1
+		if index > float64(len(mp.playlist)-1) {
+			index = 0
+		}
+		song = mp.playlist[int(index)]
+	}
+	outgoing, incoming := mp.player, mp.fader
+	mp.lock.Unlock()
+	if skip {
+		return
+	}
+	if song.IsLive() {
+		// Live streams start with the plain transition, they are not cached
+		return
+	}
+	url := mp.fetch(song.GetURL())
+
+	info, err := mp.prober().GetMediaInfo(url)
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+	duration, err := info.GetDuration()
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+	if mp.crossfadeDuration(duration) <= 0 {
+		Logger.Debug("song too short for crossfade", song.Name)
+		return
+	}
+
+	incoming.SetVolume(0)
+	incoming.SetGain(mp.trackGain(song))
+	finish, err := incoming.Play(url, 0)
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+
+	mp.lock.Lock()
+	if mp.generation != generation || mp.nextPlayer != nil {
+		// Play, a pause or a reload took over while the song was starting
+		mp.lock.Unlock()
+		incoming.Stop()
+		return
+	}
+	mp.player, mp.fader = incoming, outgoing
+	song.Index = 0
+	song.Duration = duration
+	mp.currentSong = song
+	mp.currentIndex = index
+	mp.generation++
+	generation = mp.generation
+	mp.lock.Unlock()
+	Logger.Infof("crossfading into song %s, duration %f", song.Name, duration)
+	mp.FirePlaying()
+	mp.savePlayback(false)
+
+	mp.watchFinish(finish, generation)
+	go mp.ramp(outgoing, incoming, fade, generation)
+	mp.scheduleCrossfade(song, 0)
+	mp.pinCache()
+}
+
+// ramp fades the outgoing backend out and the incoming one in, the outgoing
+// backend is stopped afterwards
+func (mp *MusicPlayer) ramp(outgoing, incoming Backend, fade time.Duration, generation int) {
+	defer outgoing.Stop()
+
+	steps := int(fade / fadeStep)
+	for i := 1; i <= steps; i++ {
+		if !mp.isGeneration(generation) {
+			return
+		}
+		volume := mp.outputVolume()
+		incoming.SetVolume(volume * i / steps)
+		outgoing.SetVolume(volume * (steps - i) / steps)
+		time.Sleep(fadeStep)
+	}
+	incoming.SetVolume(mp.outputVolume())
+}
@@ -0,0 +1,161 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"mmfm-playback-go/internal/config"
+	"mmfm-playback-go/internal/httpclient"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FFplay represents the ffplay wrapper, ffplay can not change the volume of
+// a running playback so it is restarted at the current position instead
+type FFplay struct {
+	bin     string
+	proc    *process
+	url     string
+	offset  int
+	started time.Time
+	done    chan error
+	volume  int
+	gain    float64
+	client  *httpclient.Client
+	driver  string
+	device  string
+	lock    sync.Mutex
+}
+
+// NewFFplay creates a new FFplay instance
+func NewFFplay(bin string) *FFplay {
+	return &FFplay{
+		bin:    bin,
+		volume: 100,
+	}
+}
+
+// args builds the ffplay command line, the gain is applied with the ffmpeg
+// volume filter
+func (f *FFplay) args(url string, second int) []string {
+	args := []string{"-nodisp", "-autoexit", "-loglevel", "error", "-volume", strconv.Itoa(f.volume)}
+	if f.gain != 0 {
+		args = append(args, "-af", fmt.Sprintf("volume=%.1fdB", f.gain))
+	}
+	if second > 0 {
+		args = append(args, "-ss", strconv.Itoa(second))
+	}
+	return append(args, url)
+}
+
+// Play plays a media file from a specific time
+func (f *FFplay) Play(url string, second int) (<-chan error, error) {
+	f.Stop()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.driver == config.AudioWAV {
+		return nil, errors.New("ffplay can not record wav files, use the mplayer or native backend")
+	}
+	url, err := localURL(f.client, url)
+	if err != nil {
+		return nil, err
+	}
+	f.url = url
+	f.done = make(chan error, 1)
+	if err := f.start(second); err != nil {
+		return nil, err
+	}
+	return f.done, nil
+}
+
+// start launches ffplay, the done channel is only signaled when the process
+// has not been replaced by a restart
+func (f *FFplay) start(second int) error {
+	cmd := exec.Command(f.bin, f.args(f.url, second)...)
+	if env := sdlEnv(f.driver, f.device); len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	proc, err := startProcess(cmd)
+	if err != nil {
+		return err
+	}
+	f.proc = proc
+	f.offset = second
+	f.started = time.Now()
+
+	done := f.done
+	go func() {
+		err := proc.wait()
+		f.lock.Lock()
+		replaced := f.proc != nil && f.proc != proc
+		if f.proc == proc {
+			f.proc = nil
+		}
+		f.lock.Unlock()
+		if !replaced {
+			done <- err
+		}
+	}()
+	return nil
+}
+
+// Stop stops the current playback
+func (f *FFplay) Stop() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.proc != nil {
+		f.proc.stop()
+		f.proc = nil
+	}
+	return nil
+}
+
+// SetVolume sets the volume, a running playback is restarted at its
+// current position
+func (f *FFplay) SetVolume(volume int) error {
+	if volume < 0 {
+		volume = 0
+	}
+	if volume > 100 {
+		volume = 100
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if volume == f.volume {
+		return nil
+	}
+	f.volume = volume
+	if f.proc == nil {
+		return nil
+	}
+
+	second := f.offset + int(time.Since(f.started).Seconds())
+	f.proc.stop()
+	return f.start(second)
+}
+
+// SetGain sets the gain of the ffmpeg volume filter
+func (f *FFplay) SetGain(gain float64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.gain = gain
+}
+
+// SetClient sets the HTTP client of remote urls
+func (f *FFplay) SetClient(client *httpclient.Client) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.client = client
+}
+
+// SetOutput selects the audio output of the next Play
+func (f *FFplay) SetOutput(driver string, device string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.driver = driver
+	f.device = device
+}
@@ -0,0 +1,59 @@
+package player
+
+import "time"
+
+// isClosed checks if t is outside the configured operating hours
+func (mp *MusicPlayer) isClosed(t time.Time) bool {
+	hours := mp.config().OperatingHours
+	return hours != nil && !hours.IsOpen(t)
+}
+
+// applyOperatingHours pauses the playback when the operating hours end and
+// resumes it from the saved position when the next window starts
+func (mp *MusicPlayer) applyOperatingHours(now time.Time) {
+	closed := mp.isClosed(now)
+	mp.lock.Lock()
+	if closed == mp.closed {
+		mp.lock.Unlock()
+		return
+	}
+	mp.closed = closed
+	song, playing := mp.currentSong, !mp.pauseFlag
+	resume := false
+	var second int
+	if closed {
+		mp.resumeOnOpen = playing
+	} else if mp.resumeOnOpen && song != nil {
+		mp.resumeOnOpen = false
+		resume = true
+		second = int(song.Index)
+	}
+	mp.lock.Unlock()
+
+	if closed {
+		Logger.Info("outside operating hours, pausing playback")
+		if playing && song != nil {
+			mp.Pause()
+		}
+		return
+	}
+
+	Logger.Info("operating hours started")
+	if resume {
+		go func() {
+			err := mp.Play(song, second)
+			if err != nil {
+				Logger.Error(err)
+				mp.Next()
+			}
+		}()
+	}
+}
+
+// isForced checks if a command carries the force flag as its second param
+func isForced(params []interface{}) bool {
+	if len(params) < 2 {
+		return false
+	}
+	return params[1] == true || params[1] == "force"
+}
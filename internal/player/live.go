@@ -0,0 +1,333 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/chat"
+	"mmfm-playback-go/pkg/types"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// liveRetryDelay is the delay before the first reconnect of a dropped
+	// live stream, it grows with every failed attempt
+	liveRetryDelay = 2 * time.Second
+	// liveMaxRetries is the number of failed reconnects after which the
+	// playlist moves on to the next song
+	liveMaxRetries = 5
+	// liveStableAfter is how long a stream has to play before its failed
+	// reconnects are forgotten
+	liveStableAfter = 30 * time.Second
+)
+
+// playLive plays a live stream, it is neither cached nor probed. The
+// position of the song is the elapsed time from second on and a dropped
+// stream is reconnected
+func (mp *MusicPlayer) playLive(song *types.Song, second int) error {
+	mp.cancelCrossfade()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, err := mp.openLive(ctx, song)
+	if err != nil {
+		cancel()
+		Logger.Error(err)
+		mp.setPaused(true)
+		return err
+	}
+
+	player, generation := mp.claimPlayer()
+	player.SetVolume(mp.outputVolume())
+	player.SetGain(0)
+	finish, err := player.Play(url, 0)
+	if err != nil {
+		cancel()
+		Logger.Error(err)
+		mp.setPaused(true)
+		return err
+	}
+	mp.lock.Lock()
+	song.Index = float64(second)
+	song.Duration = 0
+	mp.pauseFlag = false
+	mp.currentSong = song
+	mp.lock.Unlock()
+	Logger.Infof("playing live stream %s, elapsed %d", song.Name, second)
+	mp.FirePlaying()
+	mp.savePlayback(false)
+
+	started := time.Now()
+	go func() {
+		if err := <-finish; err != nil {
+			Logger.Error("live playback failed:", err)
+		}
+		cancel()
+		if !mp.isPaused() && mp.isGeneration(generation) {
+			mp.reconnectLive(song, generation, started)
+		}
+	}()
+	mp.pinCache()
+	return nil
+}
+
+// reconnectLive restarts a live stream which dropped, the playlist moves on
+// once the stream keeps failing
+func (mp *MusicPlayer) reconnectLive(song *types.Song, generation int, started time.Time) {
+	for {
+		mp.lock.Lock()
+		if time.Since(started) >= liveStableAfter {
+			mp.liveRetries = 0
+		}
+		mp.liveRetries++
+		attempt := mp.liveRetries
+		mp.lock.Unlock()
+
+		if attempt > liveMaxRetries {
+			Logger.Error("live stream unavailable, skipping", song.Name)
+			mp.lock.Lock()
+			mp.liveRetries = 0
+			mp.lock.Unlock()
+			mp.Next()
+			return
+		}
+
+		time.Sleep(liveRetryDelay * time.Duration(attempt))
+		if !mp.isGeneration(generation) || mp.isStopping() {
+			return
+		}
+		Logger.Infof("reconnecting live stream %s, attempt %d", song.Name, attempt)
+		if err := mp.playLive(song, int(song.Index)); err == nil {
+			return
+		}
+		started = time.Now()
+	}
+}
+
+// openLive connects to a live stream and returns the location the backend
+// plays it from. Streams announcing ICY metadata are relayed on the loopback
+// interface, which strips the metadata and reports the stream titles, others
+// such as HLS are played from their URL
+func (mp *MusicPlayer) openLive(ctx context.Context, song *types.Song) (string, error) {
+	url := song.GetURL()
+	if !isRemote(url) {
+		return url, nil
+	}
+
+	client := mp.httpClient()
+	open := func(ctx context.Context) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Icy-MetaData", "1")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+		}
+		return resp, nil
+	}
+	resp, err := open(ctx)
+	if err != nil {
+		return "", err
+	}
+	if metaint(resp) <= 0 {
+		resp.Body.Close()
+		return url, nil
+	}
+
+	relay, err := newLiveRelay(resp, open, func(title string) {
+		mp.setStreamTitle(song, title)
+	})
+	if err != nil {
+		resp.Body.Close()
+		return "", err
+	}
+	go relay.serve(ctx)
+	return relay.url(), nil
+}
+
+// metaint returns the interval of the ICY metadata of resp, zero if it has
+// none
+func metaint(resp *http.Response) int {
+	metaint, err := strconv.Atoi(resp.Header.Get("Icy-Metaint"))
+	if err != nil || metaint < 0 {
+		return 0
+	}
+	return metaint
+}
+
+// setStreamTitle records the now playing title of a live stream and
+// broadcasts it
+func (mp *MusicPlayer) setStreamTitle(song *types.Song, title string) {
+	mp.lock.Lock()
+	changed := song.StreamTitle != title
+	song.StreamTitle = title
+	mp.lock.Unlock()
+	if !changed {
+		return
+	}
+
+	Logger.Infof("live stream %s now playing %s", song.Name, title)
+	if mp.chat != nil {
+		mp.chat.SendEvent(chat.CHAT_EVENT_MESSAGE, &chat.MessageArgs{
+			Command: chat.EVENT_NOW_PLAYING,
+			Params:  []interface{}{song, title},
+		})
+	}
+}
+
+// liveRelay serves the audio of a live stream to the backend, every request
+// reads its own connection to the stream
+type liveRelay struct {
+	listener net.Listener
+	// first is the connection opened to detect the metadata, it is handed
+	// to the first request
+	first   *http.Response
+	open    func(ctx context.Context) (*http.Response, error)
+	onTitle func(title string)
+	lock    sync.Mutex
+}
+
+// newLiveRelay creates a relay listening on a free loopback port, the first
+// request is served from first and the others from new connections of open
+func newLiveRelay(first *http.Response, open func(ctx context.Context) (*http.Response, error), onTitle func(title string)) (*liveRelay, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return &liveRelay{
+		listener: listener,
+		first:    first,
+		open:     open,
+		onTitle:  onTitle,
+	}, nil
+}
+
+// url returns the location the backend plays the relay from
+func (lr *liveRelay) url() string {
+	return "http://" + lr.listener.Addr().String() + "/live"
+}
+
+// serve relays the stream until ctx is done
+func (lr *liveRelay) serve(ctx context.Context) {
+	server := &http.Server{Handler: lr}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+		lr.lock.Lock()
+		if lr.first != nil {
+			lr.first.Body.Close()
+			lr.first = nil
+		}
+		lr.lock.Unlock()
+	}()
+	server.Serve(lr.listener)
+}
+
+// ServeHTTP implements http.Handler, the stream is copied without its
+// metadata until the backend disconnects
+func (lr *liveRelay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lr.lock.Lock()
+	resp := lr.first
+	lr.first = nil
+	lr.lock.Unlock()
+
+	if resp == nil {
+		var err error
+		resp, err = lr.open(r.Context())
+		if err != nil {
+			Logger.Error(err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	defer resp.Body.Close()
+
+	var reader io.Reader = resp.Body
+	if metaint := metaint(resp); metaint > 0 {
+		reader = &icyReader{
+			reader:    resp.Body,
+			metaint:   metaint,
+			remaining: metaint,
+			onTitle:   lr.onTitle,
+		}
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, reader)
+}
+
+// icyReader strips the ICY metadata blocks interleaved every metaint bytes
+// of the audio and reports their stream titles
+type icyReader struct {
+	reader    io.Reader
+	metaint   int
+	remaining int
+	onTitle   func(title string)
+}
+
+// Read implements io.Reader
+func (ir *icyReader) Read(p []byte) (int, error) {
+	if ir.remaining == 0 {
+		if err := ir.readMetadata(); err != nil {
+			return 0, err
+		}
+		ir.remaining = ir.metaint
+	}
+	if len(p) > ir.remaining {
+		p = p[:ir.remaining]
+	}
+	n, err := ir.reader.Read(p)
+	ir.remaining -= n
+	return n, err
+}
+
+// readMetadata consumes a metadata block, its length is given in units of
+// 16 bytes by its first byte
+func (ir *icyReader) readMetadata() error {
+	var length [1]byte
+	if _, err := io.ReadFull(ir.reader, length[:]); err != nil {
+		return err
+	}
+	if length[0] == 0 {
+		return nil
+	}
+	block := make([]byte, int(length[0])*16)
+	if _, err := io.ReadFull(ir.reader, block); err != nil {
+		return err
+	}
+	if title, ok := parseStreamTitle(string(block)); ok && ir.onTitle != nil {
+		ir.onTitle(title)
+	}
+	return nil
+}
+
+// parseStreamTitle extracts the StreamTitle of an ICY metadata block such as
+// "StreamTitle='Band - Song';"
+func parseStreamTitle(metadata string) (string, bool) {
+	const prefix = "StreamTitle='"
+	start := strings.Index(metadata, prefix)
+	if start < 0 {
+		return "", false
+	}
+	rest := metadata[start+len(prefix):]
+	end := strings.Index(rest, "';")
+	if end < 0 {
+		end = strings.LastIndex(rest, "'")
+	}
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(rest[:end]), true
+}
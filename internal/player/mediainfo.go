@@ -0,0 +1,18 @@
+package player
+
+import "mmfm-playback-go/internal/probe"
+
+// MediaDetails is the parsed ffprobe output of a media file
+type MediaDetails = probe.MediaDetails
+
+// StreamDetails describes a stream of a media file
+type StreamDetails = probe.StreamDetails
+
+// Details returns the details read by the native probe or parses the
+// ffprobe output
+func (mi *MediaInfo) Details() *MediaDetails {
+	if mi.details != nil {
+		return mi.details
+	}
+	return probe.NewMediaInfo(mi.raw).Details()
+}
@@ -2,22 +2,32 @@ package player
 
 import (
 	"fmt"
+	"io"
+	"mmfm-playback-go/internal/httpclient"
 	"os/exec"
-	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // Mplayer represents the mplayer wrapper
 type Mplayer struct {
-	bin string
-	cmd *exec.Cmd
+	bin    string
+	proc   *process
+	stdin  io.WriteCloser
+	volume int
+	gain   float64
+	client *httpclient.Client
+	driver string
+	device string
+	lock   sync.Mutex
 }
 
 // NewMplayer creates a new Mplayer instance
 func NewMplayer(bin string) *Mplayer {
 	return &Mplayer{
-		bin: bin,
+		bin:    bin,
+		volume: 100,
 	}
 }
 
@@ -26,28 +36,48 @@ func SecToString(second int) string {
 	return fmt.Sprintf("%02d:%02d:%02d", second/3600, (second/60)%60, second%60)
 }
 
-// Play plays a media file from a specific time
-func (m *Mplayer) Play(url string, second int) (<-chan bool, error) {
-	if m.cmd != nil {
-		m.Stop()
+// args builds the mplayer command line, slave mode is always enabled so the
+// volume can be changed while playing
+func (m *Mplayer) args(url string, second int) []string {
+	args := []string{"-slave", "-quiet", "-vo", "null", "-softvol", "-volume", strconv.Itoa(m.volume)}
+	if ao := mplayerOutput(m.driver, m.device); ao != "" {
+		args = append(args, "-ao", ao)
+	}
+	if m.gain != 0 {
+		// volume filter with soft clipping
+		args = append(args, "-af", fmt.Sprintf("volume=%.1f:1", m.gain))
 	}
-
-	args := []string{}
 	if second > 0 {
-		args = append(args, "-ss", SecToString(second), "-vo", "null", "-slave", fmt.Sprintf("%ds", second))
+		args = append(args, "-ss", SecToString(second))
 	}
-	args = append(args, url)
+	return append(args, url)
+}
+
+// Play plays a media file from a specific time
+func (m *Mplayer) Play(url string, second int) (<-chan error, error) {
+	m.Stop()
 
-	m.cmd = exec.Command(m.bin, args...)
-	err := m.cmd.Start()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	url, err := localURL(m.client, url)
 	if err != nil {
 		return nil, err
 	}
+	cmd := exec.Command(m.bin, m.args(url, second)...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	proc, err := startProcess(cmd)
+	if err != nil {
+		return nil, err
+	}
+	m.proc = proc
+	m.stdin = stdin
 
-	done := make(chan bool, 1)
+	done := make(chan error, 1)
 	go func() {
-		m.cmd.Wait()
-		done <- true
+		done <- proc.wait()
 	}()
 
 	return done, nil
@@ -55,20 +85,63 @@ func (m *Mplayer) Play(url string, second int) (<-chan bool, error) {
 
 // Stop stops the current playback
 func (m *Mplayer) Stop() error {
-	if m.cmd != nil {
-		if runtime.GOOS == "windows" {
-			m.cmd.Process.Kill()
-		} else {
-			m.cmd.Process.Kill()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.proc != nil {
+		if m.stdin != nil {
+			m.stdin.Close()
+			m.stdin = nil
 		}
-		m.cmd = nil
+		m.proc.stop()
+		m.proc = nil
 	}
 	return nil
 }
 
+// SetVolume sets the software volume through the slave protocol
+func (m *Mplayer) SetVolume(volume int) error {
+	if volume < 0 {
+		volume = 0
+	}
+	if volume > 100 {
+		volume = 100
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.volume = volume
+	if m.stdin == nil {
+		return nil
+	}
+	_, err := fmt.Fprintf(m.stdin, "volume %d 1\n", volume)
+	return err
+}
+
+// SetGain sets the gain of the mplayer volume filter
+func (m *Mplayer) SetGain(gain float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.gain = gain
+}
+
+// SetClient sets the HTTP client of remote urls
+func (m *Mplayer) SetClient(client *httpclient.Client) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.client = client
+}
+
+// SetOutput selects the audio output of the next Play
+func (m *Mplayer) SetOutput(driver string, device string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.driver = driver
+	m.device = device
+}
+
 // FFprobe represents the ffprobe wrapper
 type FFprobe struct {
-	bin string
+	bin    string
+	client *httpclient.Client
 }
 
 // NewFFprobe creates a new FFprobe instance
@@ -78,8 +151,17 @@ func NewFFprobe(bin string) *FFprobe {
 	}
 }
 
+// SetClient sets the HTTP client of remote urls
+func (f *FFprobe) SetClient(client *httpclient.Client) {
+	f.client = client
+}
+
 // GetMediaInfo retrieves media information
 func (f *FFprobe) GetMediaInfo(url string) (*MediaInfo, error) {
+	url, err := localURL(f.client, url)
+	if err != nil {
+		return nil, err
+	}
 	cmd := exec.Command(f.bin, "-v", "quiet", "-show_format", "-show_streams", url)
 	output, err := cmd.Output()
 	if err != nil {
@@ -94,10 +176,15 @@ func (f *FFprobe) GetMediaInfo(url string) (*MediaInfo, error) {
 // MediaInfo holds media information
 type MediaInfo struct {
 	raw string
+	// details are set by the native probe instead of the ffprobe output
+	details *MediaDetails
 }
 
 // GetDuration retrieves the duration of the media file
 func (mi *MediaInfo) GetDuration() (float64, error) {
+	if mi.details != nil {
+		return mi.details.Duration, nil
+	}
 	// Simplified implementation - in a real scenario, you would parse the raw output
 	// to extract the duration field
 	lines := strings.Split(mi.raw, "\n")
@@ -0,0 +1,286 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"mmfm-playback-go/internal/audio"
+	"mmfm-playback-go/internal/httpclient"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Native decodes MP3, FLAC, Ogg Vorbis and WAV in process and plays them
+// to an audio sink, it runs no external programs so a static binary plays
+// on its own
+type Native struct {
+	volume   int
+	gain     float64
+	client   *httpclient.Client
+	driver   string
+	device   string
+	cancel   context.CancelFunc
+	finished chan struct{}
+	lock     sync.Mutex
+}
+
+// NewNative creates a new Native instance
+func NewNative() *Native {
+	return &Native{
+		volume: 100,
+	}
+}
+
+// Play decodes url from a specific time on a goroutine, the song and the
+// output are opened before it returns so their errors are returned at once.
+// Remote songs are requested with a context which Stop cancels, so a read
+// waiting for the server does not block it
+func (n *Native) Play(url string, second int) (<-chan error, error) {
+	n.Stop()
+
+	n.lock.Lock()
+	client, driver, device := n.client, n.driver, n.device
+	n.lock.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	source, _, err := openSong(ctx, client, url)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	fail := func(err error) (<-chan error, error) {
+		source.Close()
+		cancel()
+		return nil, err
+	}
+	decoder, err := audio.NewDecoder(source)
+	if err != nil {
+		return fail(fmt.Errorf("%s: %w", url, err))
+	}
+	if second > 0 {
+		if err := decoder.Seek(float64(second)); err != nil {
+			return fail(fmt.Errorf("%s: %w", url, err))
+		}
+	}
+	sink, err := audio.NewSink(driver, device)
+	if err != nil {
+		return fail(err)
+	}
+	rate, err := sink.Open(decoder.Format().SampleRate)
+	if err != nil {
+		return fail(err)
+	}
+
+	finished := make(chan struct{})
+	n.lock.Lock()
+	n.cancel, n.finished = cancel, finished
+	n.lock.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		err := n.render(decoder, sink, rate, ctx.Done())
+		if ctx.Err() != nil {
+			// The aborted request of a stopped song is no failure
+			err = nil
+		}
+		sink.Close()
+		source.Close()
+		cancel()
+		close(finished)
+		done <- err
+	}()
+	return done, nil
+}
+
+// render writes the decoded samples to the sink until the song ends or
+// stop is closed, the volume is read for every buffer so changes apply
+// while playing
+func (n *Native) render(decoder audio.Decoder, sink audio.Sink, rate int, stop <-chan struct{}) error {
+	format := decoder.Format()
+	var resampler *audio.Resampler
+	if rate != format.SampleRate {
+		resampler = audio.NewResampler(format.SampleRate, rate)
+	}
+
+	samples := make([]float32, 2048*format.Channels)
+	var pcm, resampled []byte
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		count, err := decoder.Read(samples)
+		if count > 0 {
+			pcm = audio.Encode(pcm[:0], samples[:count], format.Channels, n.amplitude())
+			out := pcm
+			if resampler != nil {
+				resampled = resampler.Resample(resampled[:0], pcm)
+				out = resampled
+			}
+			if err := sink.Write(out); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return sink.Drain()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// amplitude returns the factor of the samples for the volume and the gain
+func (n *Native) amplitude() float32 {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return float32(float64(n.volume) / 100 * math.Pow(10, n.gain/20))
+}
+
+// Stop stops the current playback and waits until the output is released,
+// the request of a remote song is cancelled first
+func (n *Native) Stop() error {
+	n.lock.Lock()
+	cancel, finished := n.cancel, n.finished
+	n.cancel, n.finished = nil, nil
+	n.lock.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-finished
+	}
+	return nil
+}
+
+// SetVolume sets the volume, it applies to the next buffer written
+func (n *Native) SetVolume(volume int) error {
+	if volume < 0 {
+		volume = 0
+	}
+	if volume > 100 {
+		volume = 100
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.volume = volume
+	return nil
+}
+
+// SetGain sets the gain in dB applied to the samples
+func (n *Native) SetGain(gain float64) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.gain = gain
+}
+
+// SetClient sets the HTTP client of remote urls
+func (n *Native) SetClient(client *httpclient.Client) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.client = client
+}
+
+// SetOutput selects the audio output of the next Play, an empty driver
+// plays to ALSA
+func (n *Native) SetOutput(driver string, device string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.driver = driver
+	n.device = device
+}
+
+// openSong opens a local file or requests a remote url with client until
+// ctx is done, with its size or -1 if unknown. Only local files can seek,
+// the request has no timeout as live streams do not end
+func openSong(ctx context.Context, client *httpclient.Client, url string) (io.ReadCloser, int64, error) {
+	if !isRemote(url) {
+		file, err := os.Open(strings.TrimPrefix(url, "file://"))
+		if err != nil {
+			return nil, 0, err
+		}
+		stat, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+		return file, stat.Size(), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if client == nil {
+		client = httpclient.Default
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// NativeProbe reads the format and the duration of songs with the decoders
+// of the native backend, tags are not read
+type NativeProbe struct {
+	client *httpclient.Client
+}
+
+// NewNativeProbe creates a new NativeProbe instance
+func NewNativeProbe() *NativeProbe {
+	return &NativeProbe{}
+}
+
+// SetClient sets the HTTP client of remote urls
+func (np *NativeProbe) SetClient(client *httpclient.Client) {
+	np.client = client
+}
+
+// GetMediaInfo decodes the headers of url, remote MP3 and Vorbis songs can
+// not seek so their duration is estimated from their size and bit rate
+func (np *NativeProbe) GetMediaInfo(url string) (*MediaInfo, error) {
+	source, size, err := openSong(context.Background(), np.client, url)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+	decoder, err := audio.NewDecoder(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+
+	format := decoder.Format()
+	details := &MediaDetails{
+		Format:   format.Name,
+		Duration: audio.EstimateDuration(decoder, size),
+		Streams: []StreamDetails{{
+			Type:       "audio",
+			Codec:      format.Name,
+			SampleRate: format.SampleRate,
+			Channels:   format.Channels,
+		}},
+		Tags: map[string]string{},
+	}
+	switch format.Name {
+	case "vorbis":
+		details.Format = "ogg"
+	case "wav":
+		details.Streams[0].Codec = "pcm"
+	}
+	if size > 0 {
+		details.Size = size
+		if details.Duration > 0 {
+			details.BitRate = int64(float64(size*8) / details.Duration)
+		}
+	}
+	return &MediaInfo{details: details}, nil
+}
@@ -0,0 +1,138 @@
+package player
+
+import (
+	"encoding/json"
+	"mmfm-playback-go/pkg/types"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// playlistFile is the name of the last successful playlist inside the
+	// cache path
+	playlistFile = "playlist.json"
+	// reconnectInterval is how often the web API is retried while offline
+	reconnectInterval = 30 * time.Second
+)
+
+// savePlaylist persists the playlist for offline use
+func (mp *MusicPlayer) savePlaylist(list []*types.Song) {
+	content, err := json.Marshal(list)
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+	cachePath := mp.config().CachePath
+	os.MkdirAll(cachePath, 0777)
+	if err := os.WriteFile(filepath.Join(cachePath, playlistFile), content, 0644); err != nil {
+		Logger.Error(err)
+	}
+}
+
+// loadOfflinePlaylist reads the last successful playlist and keeps the songs
+// which are fully cached
+func (mp *MusicPlayer) loadOfflinePlaylist() ([]*types.Song, error) {
+	content, err := os.ReadFile(filepath.Join(mp.config().CachePath, playlistFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []*types.Song
+	if err := json.Unmarshal(content, &saved); err != nil {
+		return nil, err
+	}
+
+	mp.registerSongs(saved)
+	list := make([]*types.Song, 0, len(saved))
+	for _, song := range saved {
+		if mp.Cache().Has(song.GetURL()) {
+			list = append(list, song)
+		}
+	}
+	Logger.Infof("offline playlist has %d of %d songs cached", len(list), len(saved))
+	return list, nil
+}
+
+// goOffline switches to the cached playlist and keeps retrying the web API
+// until it is reachable again, without a saved playlist nothing plays until
+// then
+func (mp *MusicPlayer) goOffline() {
+	list, err := mp.loadOfflinePlaylist()
+	if err != nil {
+		Logger.Error(err)
+	}
+
+	mp.lock.Lock()
+	alreadyOffline := mp.offline
+	mp.offline = true
+	mp.lock.Unlock()
+
+	if len(list) > 0 {
+		mp.setPlaylist(list)
+	}
+	if !alreadyOffline {
+		Logger.Info("web API unreachable, playing cached songs only")
+		go mp.reconnect()
+	}
+}
+
+// reconnect polls the web API while offline and switches back to the online
+// playlist once it answers, the playback starts if no cached song could
+func (mp *MusicPlayer) reconnect() {
+	for {
+		time.Sleep(reconnectInterval)
+
+		list, _, err := mp.loadPlaylist(false)
+		if err != nil {
+			Logger.Debug("web API still unreachable:", err)
+			continue
+		}
+
+		Logger.Info("web API reachable again, switching to the online playlist")
+		mp.lock.Lock()
+		mp.offline = false
+		idle := mp.currentSong == nil
+		mp.lock.Unlock()
+
+		mp.updatePlaylist(list)
+		if idle && len(list) > 0 && mp.isStarted() && !mp.isStopping() {
+			if err := mp.beginPlayback(); err != nil {
+				Logger.Error(err)
+			}
+		}
+		return
+	}
+}
+
+// setPlaylist replaces the playlist, the index follows the current song if
+// it is part of the new playlist. The next song of the new playlist is pinned
+// in the cache
+func (mp *MusicPlayer) setPlaylist(list []*types.Song) {
+	mp.registerSongs(list)
+	mp.lock.Lock()
+	if mp.currentSong != nil {
+		for i, song := range list {
+			if song.GetURL() == mp.currentSong.GetURL() {
+				mp.currentIndex = float64(i)
+				break
+			}
+		}
+	}
+	mp.playlist = list
+	mp.lock.Unlock()
+	mp.pinCache()
+}
+
+// registerSongs tells the cache key strategy the song IDs of list and of the
+// current song
+func (mp *MusicPlayer) registerSongs(list []*types.Song) {
+	keyer := mp.cacheKeyer()
+	if keyer == nil {
+		return
+	}
+	mp.lock.Lock()
+	current := mp.currentSong
+	mp.lock.Unlock()
+	keyer.Register(append([]*types.Song{current}, list...)...)
+}
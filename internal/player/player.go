@@ -1,15 +1,20 @@
 package player
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"mmfm-playback-go/internal/api"
 	"mmfm-playback-go/internal/cache"
 	"mmfm-playback-go/internal/chat"
 	"mmfm-playback-go/internal/config"
+	"mmfm-playback-go/internal/httpclient"
 	"mmfm-playback-go/internal/logger"
+	"mmfm-playback-go/internal/playlist"
+	"mmfm-playback-go/internal/probe"
 	"mmfm-playback-go/pkg/types"
-	"net/http"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -26,45 +31,316 @@ type Player interface {
 // MusicPlayer is the main music player implementation
 type MusicPlayer struct {
 	Conf         *config.PlaybackConfig
-	player       *Mplayer
-	probe        *FFprobe
+	player       Backend
+	fader        Backend
+	probe        Prober
 	playlist     []*types.Song
 	currentIndex float64
 	chat         *chat.ChatClient
 	currentSong  *types.Song
-	cache        *cache.FileCache
+	cache        cache.Cache
 	pauseFlag    bool
 	// Add fields for scheduled audio playback
 	scheduledAudioPlaying bool
 	originalPaused        bool
+	state                 *State
+	volumeSchedule        int
+	cacheLimited          bool
+	prefetcher            *cache.Prefetcher
+	keyer                 *cache.Keyer
+	client                *httpclient.Client
+	source                playlist.Source
+	offline               bool
+	// closed is set outside the operating hours, resumeOnOpen remembers
+	// whether the playback was running when they ended
+	closed       bool
+	resumeOnOpen bool
+	// generation identifies the latest playback, finish events of older
+	// playbacks are ignored
+	generation int
+	fadeTimer  *time.Timer
+	// liveRetries counts the failed reconnects of the current live stream
+	liveRetries int
+	// nextPlayer and nextFader replace the backends from the next playback
+	// on after a reload changed them
+	nextPlayer Backend
+	nextFader  Backend
+	// output is the audio output of the backends, player.output changes it
+	output     config.AudioConfig
+	scheduling bool
+	polling    bool
+	// started is set by Start, constructing a player runs no background
+	// work so the subcommands can use it
+	started bool
+	// stopping is set by Shutdown, no playback starts afterwards
+	stopping bool
+	lock     sync.Mutex
+	// settings guards the fields a reload replaces: Conf, client, source,
+	// cache, probe, keyer, prefetcher and cacheLimited. It is never held
+	// while lock is taken
+	settings sync.RWMutex
 }
 
 // NewMusicPlayer creates a new music player instance
 func NewMusicPlayer(conf *config.PlaybackConfig) *MusicPlayer {
+	c, err := newCache(conf)
+	if err != nil {
+		Logger.Error(err, ", falling back to the file cache")
+		c = cache.NewFileCache(conf.CachePath)
+	}
+	return NewMusicPlayerWithCache(conf, c)
+}
+
+// NewMusicPlayerWithCache creates a new music player instance storing its
+// songs in c
+func NewMusicPlayerWithCache(conf *config.PlaybackConfig, c cache.Cache) *MusicPlayer {
 	player := &MusicPlayer{
-		Conf:         conf,
-		player:       NewMplayer(conf.FFMpegConf.MPlayer),
-		probe:        NewFFprobe(conf.FFMpegConf.FFProbe),
-		playlist:     make([]*types.Song, 0),
-		currentIndex: 0,
-		pauseFlag:    true,
-		cache:        cache.NewFileCache(conf.CachePath),
-		chat:         chat.NewChatClient(conf.WebSocketAPI),
+		Conf:           conf,
+		player:         NewBackend(conf),
+		fader:          NewBackend(conf),
+		probe:          NewProber(conf),
+		playlist:       make([]*types.Song, 0),
+		currentIndex:   0,
+		pauseFlag:      true,
+		cache:          c,
+		chat:           chat.NewChatClient(conf.WebSocketAPI),
+		state:          loadState(conf.CachePath),
+		volumeSchedule: -1,
+		client:         httpclient.Default,
 	}
 
-	// Initialize scheduled audio handling if scheduled audios are configured
-	if len(conf.ScheduledAudios) > 0 {
-		go player.handleScheduledAudios()
+	if conf.Audio != nil {
+		player.output = *conf.Audio
 	}
+	player.setClient(newClient(conf))
+	player.setCache(c)
 
 	return player
 }
 
-// handleScheduledAudios manages scheduled audio playback
+// newClient creates the HTTP client of the configuration, the default
+// client is used when it is invalid
+func newClient(conf *config.PlaybackConfig) *httpclient.Client {
+	client, err := httpclient.New(conf.HTTP, conf.WebAPI)
+	if err != nil {
+		Logger.Error("invalid HTTP configuration:", err)
+		return httpclient.Default
+	}
+	return client
+}
+
+// setClient makes the backends, the probe and the playlist source request
+// with client
+func (mp *MusicPlayer) setClient(client *httpclient.Client) {
+	mp.player.SetClient(client)
+	mp.fader.SetClient(client)
+	mp.prober().SetClient(client)
+	source := newSource(mp.config(), client)
+
+	mp.settings.Lock()
+	defer mp.settings.Unlock()
+	mp.client = client
+	mp.source = source
+}
+
+// setCache stores the songs in c, tuned by the cache options. The background
+// work of the previous cache is stopped
+func (mp *MusicPlayer) setCache(c cache.Cache) {
+	if fc, ok := mp.Cache().(*cache.FileCache); ok {
+		fc.StopEviction()
+	}
+	if prefetcher := mp.cachePrefetcher(); prefetcher != nil {
+		prefetcher.Stop()
+	}
+
+	conf := mp.config()
+	var keyer *cache.Keyer
+	var prefetcher *cache.Prefetcher
+	limited := false
+	if requesting, ok := c.(interface{ SetClient(*httpclient.Client) }); ok {
+		requesting.SetClient(mp.httpClient())
+	}
+	if conf.CacheOptions != nil && conf.CacheOptions.Key != "" && conf.CacheOptions.Key != cache.KeyURL {
+		if keyed, ok := c.(interface{ SetKeyer(*cache.Keyer) }); ok {
+			keyer = cache.NewKeyer(conf.CacheOptions.Key, conf.CacheOptions.KeyParams...)
+			keyed.SetKeyer(keyer)
+		}
+	}
+
+	// The cache options beyond the storage type tune the file cache
+	if fc, ok := c.(*cache.FileCache); ok {
+		if conf.CacheOptions != nil {
+			fc.SetDedup(conf.CacheOptions.Dedup)
+			if conf.CacheOptions.Mode != "" {
+				fc.SetMode(conf.CacheOptions.Mode)
+			}
+			maxSize, maxAge, err := conf.CacheOptions.Limits()
+			if err != nil {
+				Logger.Error(err)
+			} else if maxSize > 0 || maxAge > 0 {
+				fc.SetLimits(maxSize, maxAge)
+				limited = true
+				if mp.isStarted() {
+					fc.StartEviction(conf.CacheOptions.Interval())
+				}
+			}
+			if conf.CacheOptions.Prefetch {
+				rate, _ := config.ParseSize(conf.CacheOptions.PrefetchRate)
+				prefetcher = cache.NewPrefetcher(fc, conf.CacheOptions.PrefetchConcurrency, rate)
+				prefetcher.OnProgress(mp.firePrefetchProgress)
+			}
+		}
+		if conf.Loudness != nil {
+			fc.SetLoudnessAnalyzer(cache.NewLoudnessAnalyzer(conf.FFMpegConf.FFMpeg))
+		}
+	}
+
+	mp.settings.Lock()
+	defer mp.settings.Unlock()
+	mp.cache = c
+	mp.keyer = keyer
+	mp.prefetcher = prefetcher
+	mp.cacheLimited = limited
+}
+
+// config returns the current configuration
+func (mp *MusicPlayer) config() *config.PlaybackConfig {
+	mp.settings.RLock()
+	defer mp.settings.RUnlock()
+	return mp.Conf
+}
+
+// httpClient returns the client of the web API and the media servers
+func (mp *MusicPlayer) httpClient() *httpclient.Client {
+	mp.settings.RLock()
+	defer mp.settings.RUnlock()
+	return mp.client
+}
+
+// playlistSource returns the source the playlist is loaded from
+func (mp *MusicPlayer) playlistSource() playlist.Source {
+	mp.settings.RLock()
+	defer mp.settings.RUnlock()
+	return mp.source
+}
+
+// prober returns the prober of the configured backend
+func (mp *MusicPlayer) prober() Prober {
+	mp.settings.RLock()
+	defer mp.settings.RUnlock()
+	return mp.probe
+}
+
+// cacheKeyer returns the key strategy of the cache, nil for full URLs
+func (mp *MusicPlayer) cacheKeyer() *cache.Keyer {
+	mp.settings.RLock()
+	defer mp.settings.RUnlock()
+	return mp.keyer
+}
+
+// cachePrefetcher returns the prefetcher of the cache, nil if disabled
+func (mp *MusicPlayer) cachePrefetcher() *cache.Prefetcher {
+	mp.settings.RLock()
+	defer mp.settings.RUnlock()
+	return mp.prefetcher
+}
+
+// isCacheLimited checks if the cache evicts by size or age
+func (mp *MusicPlayer) isCacheLimited() bool {
+	mp.settings.RLock()
+	defer mp.settings.RUnlock()
+	return mp.cacheLimited
+}
+
+// isStarted checks if Start has been called
+func (mp *MusicPlayer) isStarted() bool {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	return mp.started
+}
+
+// startScheduler runs the scheduler of the scheduled audios, the volume
+// schedules and the operating hours once any of them is configured
+func (mp *MusicPlayer) startScheduler() {
+	conf := mp.config()
+	if len(conf.ScheduledAudios) == 0 && len(conf.VolumeSchedules) == 0 && conf.OperatingHours == nil {
+		return
+	}
+
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	if !mp.scheduling {
+		mp.scheduling = true
+		go mp.handleScheduledAudios()
+	}
+}
+
+// newSource creates the playlist source of the web location, followed by
+// the fallback location if one is configured
+func newSource(conf *config.PlaybackConfig, client *httpclient.Client) playlist.Source {
+	// Without ffprobe the directories are listed with the file names
+	var prober playlist.Prober
+	if conf.FFMpegConf.FFProbe != "" {
+		prober = probe.NewFFprobe(conf.FFMpegConf.FFProbe)
+	}
+	source, err := playlist.New(conf.PlaylistType, conf.WebAPI, client, prober)
+	if err != nil {
+		Logger.Error(err, ", falling back to the JSON web API")
+		source = playlist.NewJSONSource(client, conf.WebAPI)
+	}
+	if conf.PlaylistFallback == "" {
+		return source
+	}
+
+	fallback, err := playlist.New("", conf.PlaylistFallback, client, prober)
+	if err != nil {
+		Logger.Error(err)
+		return source
+	}
+	return playlist.NewFallbackSource(source, fallback)
+}
+
+// newCache creates the cache storage selected by the configuration
+func newCache(conf *config.PlaybackConfig) (cache.Cache, error) {
+	options := conf.CacheOptions
+	if options == nil {
+		return cache.NewFileCache(conf.CachePath), nil
+	}
+
+	switch options.Type {
+	case config.CacheMemory:
+		maxSize, _, err := options.Limits()
+		if err != nil {
+			return nil, err
+		}
+		return cache.NewMemoryCache(maxSize), nil
+	case config.CacheNone:
+		return cache.NewNopCache(), nil
+	case config.CacheS3:
+		if options.S3 == nil {
+			return nil, fmt.Errorf("missing cache_options.s3")
+		}
+		return cache.NewS3Cache(cache.S3Options{
+			Endpoint:  options.S3.Endpoint,
+			Bucket:    options.S3.Bucket,
+			Region:    options.S3.Region,
+			AccessKey: options.S3.AccessKey,
+			SecretKey: options.S3.SecretKey,
+			Prefix:    options.S3.Prefix,
+		})
+	}
+	return cache.NewFileCache(conf.CachePath), nil
+}
+
+// handleScheduledAudios manages scheduled audio playback, the volume
+// schedules and the operating hours
 func (mp *MusicPlayer) handleScheduledAudios() {
 	for {
+		mp.applyOperatingHours(time.Now())
+		mp.applyVolumeSchedule(time.Now())
+
 		// Check for scheduled audios that should play now
-		for _, scheduledAudio := range mp.Conf.ScheduledAudios {
+		for _, scheduledAudio := range mp.config().ScheduledAudios {
 			if mp.isTimeToPlay(scheduledAudio.Schedule) {
 				// Play the scheduled audio
 				mp.playScheduledAudio(scheduledAudio)
@@ -77,7 +353,10 @@ func (mp *MusicPlayer) handleScheduledAudios() {
 
 // isTimeToPlay checks if the current time matches the schedule
 func (mp *MusicPlayer) isTimeToPlay(schedule string) bool {
-	if mp.scheduledAudioPlaying || mp.currentSong == nil {
+	mp.lock.Lock()
+	idle := mp.scheduledAudioPlaying || mp.currentSong == nil || mp.closed
+	mp.lock.Unlock()
+	if idle {
 		return false
 	}
 	// For now, we'll implement a simple time format check
@@ -105,7 +384,9 @@ func (mp *MusicPlayer) playScheduledAudio(scheduledAudio config.ScheduledAudio)
 	Logger.Infof("Playing scheduled audio: %s at %s", scheduledAudio.Name, scheduledAudio.URL)
 
 	// Check if we're already playing a scheduled audio
+	mp.lock.Lock()
 	if mp.scheduledAudioPlaying {
+		mp.lock.Unlock()
 		Logger.Debug("Already playing a scheduled audio, skipping:", scheduledAudio.Name)
 		return
 	}
@@ -113,9 +394,11 @@ func (mp *MusicPlayer) playScheduledAudio(scheduledAudio config.ScheduledAudio)
 	// Pause current playback and save state
 	mp.scheduledAudioPlaying = true
 	mp.originalPaused = mp.pauseFlag
+	song := mp.currentSong
+	mp.lock.Unlock()
 
-	if mp.currentSong != nil {
-		Logger.Debug("Pausing current song:", mp.currentSong.Name)
+	if song != nil {
+		Logger.Debug("Pausing current song:", song.Name)
 		mp.Pause()
 	}
 
@@ -141,9 +424,12 @@ func (mp *MusicPlayer) playScheduledAudio(scheduledAudio config.ScheduledAudio)
 // playWithoutInterrupt plays an audio without triggering normal playback events
 func (mp *MusicPlayer) playWithoutInterrupt(song *types.Song, second int) error {
 	Logger.Debug("Playing scheduled audio without interrupting normal flow", song.Name)
-	url := mp.cache.Cache(song.GetURL())
+	if mp.isStopping() {
+		return errStopping
+	}
+	url := mp.fetch(song.GetURL())
 
-	info, err := mp.probe.GetMediaInfo(url)
+	info, err := mp.prober().GetMediaInfo(url)
 	if err != nil {
 		Logger.Error(err)
 		return err
@@ -157,14 +443,16 @@ func (mp *MusicPlayer) playWithoutInterrupt(song *types.Song, second int) error
 	Logger.Debug("Scheduled audio duration:", duration)
 
 	song.Duration = duration
+	mp.switchBackends()
+	mp.player.SetVolume(mp.outputVolume())
+	mp.player.SetGain(mp.trackGain(song))
 	finish, err := mp.player.Play(url, second)
 	if err != nil {
 		return err
 	}
 
 	// Wait for the audio to finish
-	<-finish
-	return nil
+	return <-finish
 }
 
 // resumeOriginalPlayback restores the original playback after scheduled audio
@@ -172,14 +460,21 @@ func (mp *MusicPlayer) resumeOriginalPlayback() {
 	Logger.Info("Resuming original playback after scheduled audio")
 
 	// Reset scheduled audio flag
+	mp.lock.Lock()
 	mp.scheduledAudioPlaying = false
+	originalPaused, song := mp.originalPaused, mp.currentSong
+	var second int
+	if song != nil {
+		second = int(song.Index)
+	}
+	mp.lock.Unlock()
 
 	// If original playback was not paused, resume it
-	if !mp.originalPaused {
-		if mp.currentSong != nil {
+	if !originalPaused {
+		if song != nil {
 			// Resume from the saved position
 			go func() {
-				err := mp.Play(mp.currentSong, int(mp.currentSong.Index))
+				err := mp.Play(song, second)
 				if err != nil {
 					Logger.Error("Error resuming original playback:", err)
 					// If resume fails, continue with normal playback
@@ -192,24 +487,42 @@ func (mp *MusicPlayer) resumeOriginalPlayback() {
 		}
 	} else {
 		// Original was paused, so keep it paused
-		mp.pauseFlag = true
+		mp.setPaused(true)
 		mp.FirePause()
 	}
 }
 
 // Pause pauses the current playback
 func (mp *MusicPlayer) Pause() {
-	Logger.Debug("Pausing song", mp.currentSong.Name)
-	mp.pauseFlag = true
-	mp.player.Stop()
+	if song := mp.current(); song != nil {
+		Logger.Debug("Pausing song", song.Name)
+	}
+	mp.setPaused(true)
+	mp.stopPlayback()
 	mp.FirePause()
 }
 
 // Start initializes and starts the music player
 func (mp *MusicPlayer) Start() error {
+	mp.lock.Lock()
+	mp.started = true
+	mp.lock.Unlock()
+	conf := mp.config()
+	// Backends a crashed run left behind would play on top of the new ones
+	pidPath := filepath.Join(conf.CachePath, pidFileName)
+	reapOrphans(pidPath)
+	processes.setPath(pidPath)
+	mp.startScheduler()
+
+	if conf.API != "" {
+		api.NewServer(conf.API, func() interface{} {
+			return mp.Status()
+		}).Start()
+	}
+
 	retryCounter := 0
 start:
-	list, err := LoadPlaylist(mp.Conf.WebAPI)
+	list, _, err := mp.loadPlaylist(false)
 	retryCounter++
 	if err != nil {
 		Logger.Error(err)
@@ -218,39 +531,86 @@ start:
 			time.Sleep(time.Second * 2)
 			goto start
 		}
-		return err
-	}
-	mp.playlist = list
+		// The daemon stays up without cached songs, reconnect starts the
+		// playback once the web API answers
+		mp.goOffline()
+	} else {
+		mp.savePlaylist(list)
+		mp.setPlaylist(list)
 
-	go mp.cache.Clean(mp.playlist)
+		// A size limited cache keeps the songs of previous playlists until
+		// they are evicted
+		if !mp.isCacheLimited() {
+			go mp.Cache().Clean(mp.playlist)
+		}
+		mp.prefetch()
+	}
 
 	if len(mp.playlist) > 0 {
-		song, err := mp.GetSongInPlayList(int(mp.currentIndex))
-		if err != nil {
+		if err := mp.beginPlayback(); err != nil {
 			Logger.Error(err)
 			return err
 		}
+	}
+	// The songs to resume and the scheduled audios are pinned before the
+	// first eviction
+	mp.pinCache()
+	if fc, ok := mp.Cache().(*cache.FileCache); ok && mp.isCacheLimited() {
+		fc.StartEviction(conf.CacheOptions.Interval())
+	}
+
+	go mp.TrackPlaying()
+	mp.startPolling()
+	mp.Listen()
+
+	return nil
+}
+
+// beginPlayback starts the saved or the first song of the playlist, outside
+// the operating hours or after a pause at the last shutdown it only waits
+func (mp *MusicPlayer) beginPlayback() error {
+	second, paused := mp.restorePlayback()
+	mp.lock.Lock()
+	index := int(mp.currentIndex)
+	mp.lock.Unlock()
+	song, err := mp.GetSongInPlayList(index)
+	if err != nil {
+		return err
+	}
+	if closed := mp.isClosed(time.Now()); closed || paused {
+		if closed {
+			Logger.Info("outside operating hours, waiting for the next window")
+		} else {
+			Logger.Info("paused at the last shutdown, waiting for player.continue")
+		}
+		mp.lock.Lock()
+		song.Index = float64(second)
+		mp.currentSong = song
+		if closed {
+			mp.closed = true
+			mp.resumeOnOpen = !paused
+		}
+		mp.lock.Unlock()
+	} else {
 		go func() {
-			err := mp.Play(song, 0)
+			err := mp.Play(song, second)
 			if err != nil {
 				Logger.Error(err)
 				mp.Next()
 			}
 		}()
 	}
-
-	go mp.TrackPlaying()
-	mp.Listen()
-
 	return nil
 }
 
 // Listen handles incoming chat messages
 func (mp *MusicPlayer) Listen() error {
 	listener, err := mp.chat.Listen()
-	if err != nil {
+	for err != nil {
+		// Keep playing while the chat server is unreachable
 		Logger.Error(err)
-		return err
+		time.Sleep(reconnectInterval)
+		listener, err = mp.chat.Listen()
 	}
 
 	for {
@@ -258,22 +618,22 @@ func (mp *MusicPlayer) Listen() error {
 		switch msg.Command {
 		case "player.play":
 			if len(msg.Params) > 1 {
-				mp.pauseFlag = true
-
 				index, ok := msg.Params[1].(float64)
-				if ok && index != mp.currentIndex {
-					mp.currentIndex = index
-				} else {
-					mp.currentIndex = 0
+				mp.lock.Lock()
+				mp.pauseFlag = true
+				if !ok || index == mp.currentIndex {
+					index = 0
 				}
+				mp.currentIndex = index
+				mp.lock.Unlock()
 
-				song, err := mp.GetSongInPlayList(int(mp.currentIndex))
+				song, err := mp.GetSongInPlayList(int(index))
 				if err != nil {
 					Logger.Error(err)
 					mp.Next()
 					break
 				}
-				mp.player.Stop()
+				mp.stopPlayback()
 				err = mp.Play(song, 0)
 				if err != nil {
 					Logger.Error(err)
@@ -284,152 +644,317 @@ func (mp *MusicPlayer) Listen() error {
 			break
 
 		case "player.continue":
+			mp.lock.Lock()
+			closed, song := mp.closed, mp.currentSong
+			mp.lock.Unlock()
+			if closed && !isForced(msg.Params) {
+				Logger.Info("outside operating hours, ignoring player.continue")
+				mp.FirePause()
+				break
+			}
+			if song == nil {
+				// The playlist is not loaded yet
+				Logger.Info("no song to continue, ignoring player.continue")
+				break
+			}
+			mp.lock.Lock()
+			mp.resumeOnOpen = false
 			mp.pauseFlag = false
-			go mp.Play(mp.currentSong, int(mp.currentSong.Index))
+			second := int(song.Index)
+			mp.lock.Unlock()
+			go mp.Play(song, second)
 			break
 
 		case "player.pause":
-			Logger.Debug("pause song", mp.currentSong.Name)
-			mp.pauseFlag = true
-			mp.player.Stop()
+			if song := mp.current(); song != nil {
+				Logger.Debug("pause song", song.Name)
+			}
+			mp.setPaused(true)
+			mp.stopPlayback()
 			mp.FirePause()
 			break
 
 		case "player.current":
-			if mp.chat != nil {
-				if mp.pauseFlag {
-					mp.FirePause()
-				} else {
-					mp.FirePlaying()
+			mp.FireCurrent()
+			break
+
+		case chat.EVENT_VOLUME:
+			if len(msg.Params) > 1 {
+				current, _ := mp.volume()
+				volume, err := parseVolume(msg.Params[1], current)
+				if err != nil {
+					Logger.Error(err)
+					break
+				}
+				mp.SetVolume(volume)
+			}
+			break
+
+		case chat.EVENT_OUTPUT:
+			if len(msg.Params) > 1 {
+				mp.lock.Lock()
+				current := mp.output
+				mp.lock.Unlock()
+				output, err := parseOutput(msg.Params[1], current)
+				if err != nil {
+					Logger.Error(err)
+					break
 				}
+				mp.SetOutput(output)
 			}
 			break
 
+		case chat.EVENT_MUTE:
+			mp.Mute()
+			break
+
+		case chat.EVENT_UNMUTE:
+			mp.Unmute()
+			break
+
 		case "update":
 			Logger.Debug("update playlist")
-			list, err := LoadPlaylist(mp.Conf.WebAPI)
+			list, _, err := mp.loadPlaylist(false)
 			if err != nil {
 				Logger.Error(err)
+				mp.goOffline()
 				break
 			}
-			mp.playlist = list
+			mp.updatePlaylist(list)
 			break
 		}
 	}
-
-	return nil
 }
 
 // FirePause sends a pause event
 func (mp *MusicPlayer) FirePause() {
-	if mp.chat != nil && mp.currentSong != nil {
-		mp.currentSong.URL = mp.currentSong.GetURL()
-		mp.chat.SendEvent("msg", &chat.MessageArgs{
-			Command: "player.pause",
-			Params: []interface{}{
-				mp.currentSong,
-				mp.currentIndex,
-				mp.currentSong.Index,
-				mp.currentSong.Duration,
-			},
-		})
-	}
+	mp.fireState(chat.EVENT_PAUSE)
 }
 
 // FirePlaying sends a playing event
 func (mp *MusicPlayer) FirePlaying() {
-	if mp.chat != nil && mp.currentSong != nil {
-		mp.currentSong.URL = mp.currentSong.GetURL()
-		if !mp.pauseFlag {
-			mp.currentSong.Index = mp.currentSong.Index + 1
-		}
-		mp.chat.SendEvent("msg", &chat.MessageArgs{
-			Command: "player.playing",
-			Params: []interface{}{
-				mp.currentSong,
-				mp.currentIndex,
-				mp.currentSong.Index,
-				mp.currentSong.Duration,
-			},
-		})
+	mp.lock.Lock()
+	if mp.chat != nil && mp.currentSong != nil && !mp.pauseFlag {
+		mp.currentSong.Index = mp.currentSong.Index + 1
 	}
+	mp.lock.Unlock()
+	mp.fireState(chat.EVENT_PLAYING)
+}
+
+// FireCurrent sends the current state without advancing the position
+func (mp *MusicPlayer) FireCurrent() {
+	if mp.isPaused() {
+		mp.fireState(chat.EVENT_PAUSE)
+	} else {
+		mp.fireState(chat.EVENT_PLAYING)
+	}
+}
+
+// fireState broadcasts the state of the current song with the given command
+func (mp *MusicPlayer) fireState(command string) {
+	if mp.chat == nil {
+		return
+	}
+	mp.lock.Lock()
+	if mp.currentSong == nil {
+		mp.lock.Unlock()
+		return
+	}
+	mp.currentSong.URL = mp.currentSong.GetURL()
+	song := *mp.currentSong
+	params := []interface{}{&song, mp.currentIndex, song.Index, song.Duration, mp.state.Volume, mp.state.Muted}
+	mp.lock.Unlock()
+	mp.chat.SendEvent(chat.CHAT_EVENT_MESSAGE, &chat.MessageArgs{
+		Command: command,
+		Params:  params,
+	})
 }
 
 // TrackPlaying continuously sends playing events
 func (mp *MusicPlayer) TrackPlaying() {
 	for {
-		if !mp.pauseFlag {
+		if !mp.isPaused() {
 			mp.FirePlaying()
 		}
 		time.Sleep(time.Second * 1)
 	}
 }
 
-// LoadPlaylist loads a playlist from a web API
-func LoadPlaylist(apiURL string) ([]*types.Song, error) {
-	// This is a placeholder implementation
-	// In a real implementation, you would make an HTTP request to apiURL
-	// and parse the response to get a list of songs
-	Logger.Info("Loading playlist from", apiURL)
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	var playlist []*types.Song
-	err = json.NewDecoder(resp.Body).Decode(&playlist)
-	if err != nil {
-		return nil, err
-	}
-	Logger.Debug("Loaded playlist:", playlist)
-	return playlist, nil
-}
-
 // Play plays a song from a specific time
 func (mp *MusicPlayer) Play(song *types.Song, second int) error {
 	Logger.Debug("play song", song.Name)
-	url := mp.cache.Cache(song.GetURL())
+	if mp.isStopping() {
+		return errStopping
+	}
+	if song.IsLive() {
+		return mp.playLive(song, second)
+	}
+	mp.cancelCrossfade()
+	url := mp.fetch(song.GetURL())
 
-	info, err := mp.probe.GetMediaInfo(url)
+	info, err := mp.prober().GetMediaInfo(url)
 	if err != nil {
 		Logger.Error(err)
-		mp.pauseFlag = true
+		mp.setPaused(true)
 		return err
 	}
-	song.Index = float64(second)
 	duration, err := info.GetDuration()
 	if err != nil {
 		Logger.Error(err)
-		mp.pauseFlag = true
+		mp.setPaused(true)
 		return err
 	}
 	Logger.Debug(duration)
 
+	player, generation := mp.claimPlayer()
+	player.SetVolume(mp.outputVolume())
+	player.SetGain(mp.trackGain(song))
+	finish, err := player.Play(url, second)
+	if err != nil {
+		Logger.Error(err)
+		mp.setPaused(true)
+		return err
+	}
+	mp.lock.Lock()
+	song.Index = float64(second)
 	song.Duration = duration
-	finish, err := mp.player.Play(url, second)
 	mp.pauseFlag = false
 	mp.currentSong = song
-	mp.currentSong.Duration = duration
+	mp.lock.Unlock()
 	logger.Logger.Infof("playing song %s, duration %f, start %d", song.Name, duration, second)
 	mp.FirePlaying()
+	mp.savePlayback(false)
+
+	mp.watchFinish(finish, generation)
+	mp.scheduleCrossfade(song, second)
+	mp.pinCache()
+
+	return nil
+}
+
+// pinCache protects the current and next songs as well as the scheduled
+// audios from cache eviction, before the first song plays the current one
+// is the song at the current index
+func (mp *MusicPlayer) pinCache() {
+	keys := []string{}
+	mp.lock.Lock()
+	current := mp.currentSong
+	var next *types.Song
+	if len(mp.playlist) > 0 {
+		index := int(mp.currentIndex) % len(mp.playlist)
+		if current == nil {
+			current = mp.playlist[index]
+		}
+		next = mp.playlist[(index+1)%len(mp.playlist)]
+	}
+	mp.lock.Unlock()
+	for _, song := range []*types.Song{current, next} {
+		if song != nil && !song.IsLive() {
+			keys = append(keys, song.GetURL())
+		}
+	}
+	for _, scheduledAudio := range mp.config().ScheduledAudios {
+		keys = append(keys, scheduledAudio.URL)
+	}
+	mp.Cache().Pin(keys...)
+}
+
+// claimPlayer starts a new playback generation and returns the backend it
+// plays on, a crossfade of an older generation no longer swaps the backends
+func (mp *MusicPlayer) claimPlayer() (Backend, int) {
+	mp.switchBackends()
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	mp.generation++
+	return mp.player, mp.generation
+}
 
+// watchFinish moves on to the next song once the playback of generation
+// ends, unless it has been superseded in the meantime
+func (mp *MusicPlayer) watchFinish(finish <-chan error, generation int) {
 	go func() {
-		<-finish
-		if !mp.pauseFlag {
+		if err := <-finish; err != nil {
+			Logger.Error("playback failed:", err)
+		}
+		if !mp.isPaused() && mp.isGeneration(generation) {
 			mp.Next()
 		}
 	}()
+}
 
-	return nil
+// nextGeneration starts a new playback generation
+func (mp *MusicPlayer) nextGeneration() int {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	mp.generation++
+	return mp.generation
+}
+
+// isGeneration reports whether generation is still the latest playback
+func (mp *MusicPlayer) isGeneration(generation int) bool {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	return mp.generation == generation
+}
+
+// stopPlayback stops every backend, including a crossfade in progress
+func (mp *MusicPlayer) stopPlayback() {
+	mp.cancelCrossfade()
+	mp.nextGeneration()
+	mp.player.Stop()
+	mp.fader.Stop()
+}
+
+// fetch resolves the location the backends play key from, the original URL
+// is used when the cache fails
+func (mp *MusicPlayer) fetch(key string) string {
+	url, err := mp.Cache().Fetch(context.Background(), key)
+	if err != nil {
+		Logger.Error(err)
+		return key
+	}
+	return url
+}
+
+// trackGain returns the gain in dB that brings the song to the configured
+// loudness target, songs which are not analyzed yet are played unchanged
+func (mp *MusicPlayer) trackGain(song *types.Song) float64 {
+	target := mp.config().Loudness
+	if target == nil {
+		return 0
+	}
+	loudness, ok := mp.Cache().Loudness(song.GetURL())
+	if !ok {
+		return 0
+	}
+	return loudness.Gain(target.Target)
+}
+
+// isPaused checks if the playback is paused
+func (mp *MusicPlayer) isPaused() bool {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	return mp.pauseFlag
+}
+
+// setPaused marks the playback as paused or playing
+func (mp *MusicPlayer) setPaused(paused bool) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	mp.pauseFlag = paused
+}
+
+// current returns the current song, nil before the first playlist loads
+func (mp *MusicPlayer) current() *types.Song {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	return mp.currentSong
 }
 
 // GetSongInPlayList retrieves a song from the playlist by index
 func (mp *MusicPlayer) GetSongInPlayList(index int) (*types.Song, error) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
 	if index >= 0 && index < len(mp.playlist) {
 		return mp.playlist[index], nil
 	}
@@ -444,10 +969,18 @@ func (mp *MusicPlayer) GetSongInPlayList(index int) (*types.Song, error) {
 
 // Next plays the next song in the playlist
 func (mp *MusicPlayer) Next() {
+	mp.lock.Lock()
+	if len(mp.playlist) == 0 {
+		// Nothing to play until the web API returns a playlist
+		mp.lock.Unlock()
+		return
+	}
 	index := mp.currentIndex + 1
 	if index > float64(len(mp.playlist)-1) {
 		index = 0
 	}
-	mp.Play(mp.playlist[int(index)], 0)
 	mp.currentIndex = index
+	song := mp.playlist[int(index)]
+	mp.lock.Unlock()
+	mp.Play(song, 0)
 }
@@ -1,11 +1,24 @@
 package player
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/audio"
+	"mmfm-playback-go/internal/cache"
 	"mmfm-playback-go/internal/config"
+	"mmfm-playback-go/internal/httpclient"
 	"mmfm-playback-go/pkg/types"
 	"mmfm-playback-go/tests"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 var conf *config.PlaybackConfig
@@ -73,3 +86,581 @@ func TestMusicPlayer_Start(t *testing.T) {
 		t.Error("Expected no error, got:", err)
 	}
 }
+
+func TestCrossfadeDuration(t *testing.T) {
+	player := &MusicPlayer{
+		Conf: &config.PlaybackConfig{Crossfade: 5},
+	}
+
+	if fade := player.crossfadeDuration(180); fade != 5*time.Second {
+		t.Errorf("Expected crossfade of 5s, got %v", fade)
+	}
+
+	// Songs shorter than twice the fade are not crossfaded
+	if fade := player.crossfadeDuration(9); fade != 0 {
+		t.Errorf("Expected no crossfade for short song, got %v", fade)
+	}
+
+	player.Conf.Backend = config.BackendNative
+	if fade := player.crossfadeDuration(180); fade != 0 {
+		t.Errorf("Expected no crossfade with the native backend, got %v", fade)
+	}
+
+	player.Conf.Backend = ""
+	player.Conf.Crossfade = 0
+	if fade := player.crossfadeDuration(180); fade != 0 {
+		t.Errorf("Expected crossfade to be disabled, got %v", fade)
+	}
+
+	// A crossfade armed before another playback took over does nothing
+	outgoing, incoming := NewNative(), NewNative()
+	player = &MusicPlayer{
+		Conf:       &config.PlaybackConfig{Crossfade: 5},
+		player:     outgoing,
+		fader:      incoming,
+		playlist:   []*types.Song{{Name: "first"}, {Name: "second"}},
+		generation: 2,
+	}
+	player.crossfade(5*time.Second, 1)
+	if player.player != outgoing || player.currentIndex != 0 || player.generation != 2 {
+		t.Error("Expected a superseded crossfade to keep the playback")
+	}
+}
+
+func TestMplayerArgs(t *testing.T) {
+	mplayer := NewMplayer("/usr/bin/mplayer")
+	mplayer.SetVolume(40)
+
+	args := strings.Join(mplayer.args("song.mp3", 75), " ")
+	expected := "-slave -quiet -vo null -softvol -volume 40 -ss 00:01:15 song.mp3"
+	if args != expected {
+		t.Errorf("Expected args to be '%s', got '%s'", expected, args)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// The crossfade changes the volume while the player is stopped
+	bin := filepath.Join(t.TempDir(), "mplayer")
+	os.WriteFile(bin, []byte("#!/bin/sh\ncat > /dev/null\n"), 0755)
+	mplayer = NewMplayer(bin)
+	if _, err := mplayer.Play("song.mp3", 0); err != nil {
+		t.Fatal("Play should not return error:", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			mplayer.SetVolume(i)
+		}
+	}()
+	mplayer.Stop()
+	<-done
+}
+
+func TestParseVolume(t *testing.T) {
+	cases := []struct {
+		param    interface{}
+		expected int
+	}{
+		{float64(30), 30},
+		{"45", 45},
+		{"+10", 60},
+		{"-20", 30},
+	}
+
+	for _, c := range cases {
+		volume, err := parseVolume(c.param, 50)
+		if err != nil {
+			t.Errorf("parseVolume(%v) should not return error: %v", c.param, err)
+			continue
+		}
+		if volume != c.expected {
+			t.Errorf("Expected volume of %v to be %d, got %d", c.param, c.expected, volume)
+		}
+	}
+
+	if _, err := parseVolume("loud", 50); err == nil {
+		t.Error("Expected error for invalid volume")
+	}
+}
+
+func TestStatePersistence(t *testing.T) {
+	tempDir := t.TempDir()
+
+	state := loadState(tempDir)
+	if state.Volume != defaultVolume || state.Muted {
+		t.Errorf("Expected default state, got %+v", state)
+	}
+
+	state.Volume = 35
+	state.Muted = true
+	if err := state.save(tempDir); err != nil {
+		t.Fatal("save should not return error:", err)
+	}
+
+	state = loadState(tempDir)
+	if state.Volume != 35 || !state.Muted {
+		t.Errorf("Expected persisted state, got %+v", state)
+	}
+}
+
+func TestShutdownAndResume(t *testing.T) {
+	conf := &config.PlaybackConfig{
+		FFMpegConf:   &config.FFmpegConfig{},
+		WebSocketAPI: "ws://localhost:1",
+		CachePath:    t.TempDir(),
+	}
+	songs := func() []*types.Song {
+		return []*types.Song{{Name: "first", URL: "http://mmfm/1.mp3"}, {Name: "second", URL: "http://mmfm/2.mp3"}}
+	}
+
+	player := NewMusicPlayerWithCache(conf, cache.NewNopCache())
+	player.playlist = songs()
+	player.currentIndex = 1
+	player.currentSong = player.playlist[1]
+	player.currentSong.Index = 42
+	player.pauseFlag = false
+	// The status API and the scheduler read the state during the shutdown
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				player.Status()
+				player.isTimeToPlay("00:00")
+			}
+		}
+	}()
+	player.Shutdown()
+	close(stop)
+	<-done
+
+	if err := player.Play(player.playlist[0], 0); err != errStopping {
+		t.Errorf("Expected no playback after the shutdown, got %v", err)
+	}
+	saved := loadState(conf.CachePath).Playback
+	if saved == nil || saved.URL != "http://mmfm/2.mp3" || saved.Index != 1 || saved.Position != 42 || saved.Paused {
+		t.Fatalf("Expected the playback to be saved, got %+v", saved)
+	}
+
+	// The song is found by its URL when the playlist changed
+	player = NewMusicPlayerWithCache(conf, cache.NewNopCache())
+	player.playlist = append([]*types.Song{{Name: "new", URL: "http://mmfm/new.mp3"}}, songs()...)
+	if second, paused := player.restorePlayback(); second != 42 || paused || player.currentIndex != 2 {
+		t.Errorf("Expected to resume the second song at 42, got %d at index %v", second, player.currentIndex)
+	}
+
+	conf.Resume = config.ResumeSong
+	player.playlist = songs()[:1]
+	player.currentIndex = 0
+	if second, _ := player.restorePlayback(); second != 0 || player.currentIndex != 0 {
+		t.Errorf("Expected a removed song to start the playlist over, got %d at index %v", second, player.currentIndex)
+	}
+
+	conf.Resume = config.ResumeOff
+	player.playlist = songs()
+	if second, _ := player.restorePlayback(); second != 0 || player.currentIndex != 0 {
+		t.Errorf("Expected no resume when disabled, got %d at index %v", second, player.currentIndex)
+	}
+}
+
+func TestFFplayArgs(t *testing.T) {
+	ffplay := NewFFplay("/usr/bin/ffplay")
+	ffplay.SetVolume(40)
+	ffplay.SetGain(-3)
+
+	args := strings.Join(ffplay.args("song.mp3", 75), " ")
+	expected := "-nodisp -autoexit -loglevel error -volume 40 -af volume=-3.0dB -ss 75 song.mp3"
+	if args != expected {
+		t.Errorf("Expected args to be '%s', got '%s'", expected, args)
+	}
+
+	// Authenticated urls are relayed so the token stays off the command line
+	client, _ := httpclient.New(&config.HTTPConfig{BearerToken: "secret"}, "http://mmfm/song/get")
+	url, err := localURL(client, "http://mmfm/song.mp3")
+	if err != nil || !strings.HasPrefix(url, "http://127.0.0.1:") {
+		t.Errorf("Expected a relayed url, got %s %v", url, err)
+	}
+	if args := strings.Join(ffplay.args(url, 0), " "); strings.Contains(args, "secret") {
+		t.Errorf("Expected no token in the args, got '%s'", args)
+	}
+	if url, _ := localURL(client, "http://cdn/song.mp3"); url != "http://cdn/song.mp3" {
+		t.Errorf("Expected other hosts to be played directly, got %s", url)
+	}
+}
+
+func TestAudioOutput(t *testing.T) {
+	mplayer := NewMplayer("/usr/bin/mplayer")
+	mplayer.SetOutput(config.AudioALSA, "hw:CARD=Device,DEV=0")
+	args := strings.Join(mplayer.args("song.mp3", 0), " ")
+	expected := "-slave -quiet -vo null -softvol -volume 100 -ao alsa:device=%20%hw:CARD=Device,DEV=0 song.mp3"
+	if args != expected {
+		t.Errorf("Expected args to be '%s', got '%s'", expected, args)
+	}
+	if ao := mplayerOutput(config.AudioPipeWire, "speakers"); ao != "pulse::%8%speakers" {
+		t.Errorf("Expected pipewire to play through pulse, got '%s'", ao)
+	}
+	if env := strings.Join(sdlEnv(config.AudioPulse, "speakers"), " "); env != "SDL_AUDIODRIVER=pulseaudio PULSE_SINK=speakers" {
+		t.Errorf("Expected the pulse sink in the environment, got '%s'", env)
+	}
+
+	cards := " 0 [PCH            ]: HDA-Intel - HDA Intel PCH\n" +
+		"                      HDA Intel PCH at 0xf7f10000 irq 32\n" +
+		" 1 [Device         ]: USB-Audio - USB Audio Device\n"
+	pcm := "00-00: ALC892 Analog : ALC892 Analog : playback 1 : capture 1\n" +
+		"00-02: ALC892 Alt Analog : ALC892 Alt Analog : capture 1\n" +
+		"01-00: USB Audio : USB Audio : playback 1 : capture 1\n"
+	devices := parseALSADevices(cards, pcm)
+	if len(devices) != 2 {
+		t.Fatalf("Expected 2 playback devices, got %v", devices)
+	}
+	if devices[1].Spec() != "alsa:hw:CARD=Device,DEV=0" || devices[1].Description != "USB Audio Device, USB Audio (hw:1,0)" {
+		t.Errorf("Unexpected USB device %+v", devices[1])
+	}
+
+	sinks := parsePulseSinks("47\talsa_output.usb-Device.analog-stereo\tPipeWire\ts16le 2ch 48000Hz\tSUSPENDED\n")
+	if len(sinks) != 1 || sinks[0].Device != "alsa_output.usb-Device.analog-stereo" || sinks[0].Description != "sink 47, suspended" {
+		t.Errorf("Unexpected sinks %+v", sinks)
+	}
+
+	current := config.AudioConfig{Driver: config.AudioALSA}
+	for param, expected := range map[interface{}]config.AudioConfig{
+		"null":         {Driver: config.AudioNull},
+		"alsa:hw:1,0":  {Driver: config.AudioALSA, Device: "hw:1,0"},
+		"hw:CARD=PCH":  {Driver: config.AudioALSA, Device: "hw:CARD=PCH"},
+		"pulse:sink-1": {Driver: config.AudioPulse, Device: "sink-1"},
+	} {
+		output, err := parseOutput(param, current)
+		if err != nil || output != expected {
+			t.Errorf("Expected %v to be %+v, got %+v (%v)", param, expected, output, err)
+		}
+	}
+	output, err := parseOutput(map[string]interface{}{"driver": "pipewire", "device": "speakers"}, current)
+	if err != nil || output.Driver != config.AudioPipeWire || output.Device != "speakers" {
+		t.Errorf("Expected the pipewire speakers, got %+v (%v)", output, err)
+	}
+	if _, err := parseOutput(map[string]interface{}{"driver": "oss"}, current); err == nil {
+		t.Error("Expected an unsupported driver to fail")
+	}
+	if _, err := parseOutput("speakers", config.AudioConfig{}); err == nil {
+		t.Error("Expected a device without driver to fail")
+	}
+}
+
+func TestNativeBackend(t *testing.T) {
+	dir := t.TempDir()
+	song := filepath.Join(dir, "song.wav")
+	sink, _ := audio.NewSink(audio.DriverWAV, song)
+	sink.Open(8000)
+	tone := make([]float32, 3200)
+	for i := range tone {
+		tone[i] = 0.5
+	}
+	sink.Write(audio.Encode(nil, tone, 2, 1))
+	sink.Close()
+
+	conf := &config.PlaybackConfig{Backend: config.BackendNative}
+	info, err := NewProber(conf).GetMediaInfo(song)
+	if err != nil {
+		t.Fatal("GetMediaInfo should not return error:", err)
+	}
+	if duration, _ := info.GetDuration(); duration != 0.2 || info.Details().Format != "wav" {
+		t.Errorf("Expected a wav song of 0.2s, got %+v", info.Details())
+	}
+
+	output := filepath.Join(dir, "output.wav")
+	backend := NewBackend(conf)
+	backend.SetOutput(config.AudioWAV, output)
+	backend.SetVolume(50)
+	finish, err := backend.Play(song, 0)
+	if err != nil {
+		t.Fatal("Play should not return error:", err)
+	}
+	select {
+	case err := <-finish:
+		if err != nil {
+			t.Errorf("Expected the song to end, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the song to end after 0.2s")
+	}
+
+	file, _ := os.Open(output)
+	decoder, err := audio.NewDecoder(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]float32, 2)
+	decoder.Read(samples)
+	file.Close()
+	if decoder.Duration() != 0.2 || samples[0] < 0.24 || samples[0] > 0.26 {
+		t.Errorf("Expected 0.2s at half the volume, got %fs at %f", decoder.Duration(), samples[0])
+	}
+
+	// Stopping is not a failure
+	finish, _ = backend.Play(song, 0)
+	backend.Stop()
+	if err := <-finish; err != nil {
+		t.Errorf("Expected no error for a stopped song, got %v", err)
+	}
+
+	// A remote song whose server stalls does not block Stop
+	content, _ := os.ReadFile(song)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content[:len(content)/2])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	finish, err = backend.Play(server.URL+"/song.wav", 0)
+	if err != nil {
+		t.Fatal("Play should not return error:", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	stopped := make(chan struct{})
+	go func() {
+		backend.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Stop to cancel the stalled request")
+	}
+	if err := <-finish; err != nil {
+		t.Errorf("Expected no error for a stopped song, got %v", err)
+	}
+
+	backend.SetOutput(config.AudioPulse, "")
+	if _, err := backend.Play(song, 0); err == nil {
+		t.Error("Expected the native backend to reject pulse")
+	}
+}
+
+func TestProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping test, requires a POSIX shell")
+	}
+
+	proc, err := startProcess(exec.Command("sh", "-c", "echo 'cannot open audio device' >&2; exit 3"))
+	if err != nil {
+		t.Fatal("startProcess should not return error:", err)
+	}
+	if err := proc.wait(); err == nil || !strings.Contains(err.Error(), "cannot open audio device") {
+		t.Errorf("Expected the exit status with the end of stderr, got %v", err)
+	}
+
+	// Stopping is not a failure
+	proc, _ = startProcess(exec.Command("sleep", "30"))
+	proc.stop()
+	if err := proc.wait(); err != nil {
+		t.Errorf("Expected no error for a stopped process, got %v", err)
+	}
+}
+
+func TestReapOrphans(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping test, requires sleep")
+	}
+
+	// A backend of a crashed run, in its own process group
+	orphan := exec.Command("sleep", "30")
+	setProcessGroup(orphan)
+	if err := orphan.Start(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), pidFileName)
+	os.WriteFile(path, []byte(fmt.Sprintf(`[{"pid": %d, "bin": "/usr/bin/sleep"}, {"pid": %d, "bin": "/usr/bin/mplayer"}]`, orphan.Process.Pid, os.Getpid())), 0644)
+
+	reapOrphans(path)
+	exited := make(chan error, 1)
+	go func() {
+		exited <- orphan.Wait()
+	}()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		orphan.Process.Kill()
+		t.Fatal("Expected the orphaned backend to be killed")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the pid file to be removed")
+	}
+}
+
+func TestOfflinePlaylist(t *testing.T) {
+	tempDir := t.TempDir()
+	player := NewMusicPlayerWithCache(&config.PlaybackConfig{
+		FFMpegConf: &config.FFmpegConfig{},
+		CachePath:  tempDir,
+	}, cache.NewMemoryCache(0))
+
+	cachedSong := filepath.Join(tempDir, "song.mp3")
+	os.WriteFile(cachedSong, []byte("mp3 content"), 0644)
+	if _, err := player.cache.Fetch(context.Background(), cachedSong); err != nil {
+		t.Fatal("Fetch should not return error:", err)
+	}
+
+	player.savePlaylist([]*types.Song{
+		{Name: "cached", URL: cachedSong},
+		{Name: "remote", URL: "http://localhost:1/song.mp3"},
+	})
+
+	list, err := player.loadOfflinePlaylist()
+	if err != nil {
+		t.Fatal("loadOfflinePlaylist should not return error:", err)
+	}
+	if len(list) != 1 || list[0].Name != "cached" {
+		t.Errorf("Expected only the cached song, got %s", tests.ToJSON(list))
+	}
+
+	// Without a saved playlist the player waits for the web API
+	player = NewMusicPlayerWithCache(&config.PlaybackConfig{
+		FFMpegConf: &config.FFmpegConfig{},
+		CachePath:  t.TempDir(),
+	}, cache.NewMemoryCache(0))
+	player.goOffline()
+	if !player.offline || len(player.playlist) != 0 {
+		t.Error("Expected to go offline with an empty playlist")
+	}
+
+	// Commands of the web UI before the playlist loads do nothing
+	player.Next()
+	player.Pause()
+	if player.current() != nil {
+		t.Error("Expected no current song without a playlist")
+	}
+}
+
+func TestNewCache(t *testing.T) {
+	for cacheType, expected := range map[string]interface{}{
+		"":                 &cache.FileCache{},
+		config.CacheMemory: &cache.MemoryCache{},
+		config.CacheNone:   &cache.NopCache{},
+		config.CacheS3:     &cache.S3Cache{},
+	} {
+		c, err := newCache(&config.PlaybackConfig{
+			CachePath: t.TempDir(),
+			CacheOptions: &config.CacheConfig{
+				Type: cacheType,
+				S3:   &config.S3Config{Endpoint: "http://127.0.0.1:9000", Bucket: "music"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("newCache(%q) should not return error: %v", cacheType, err)
+		}
+		if fmt.Sprintf("%T", c) != fmt.Sprintf("%T", expected) {
+			t.Errorf("Expected %T for type %q, got %T", expected, cacheType, c)
+		}
+	}
+}
+
+func TestPinCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 10)))
+	}))
+	defer server.Close()
+
+	memory := cache.NewMemoryCache(25)
+	player := NewMusicPlayerWithCache(&config.PlaybackConfig{
+		FFMpegConf: &config.FFmpegConfig{},
+		CachePath:  t.TempDir(),
+	}, memory)
+	first, second, third := server.URL+"/1.mp3", server.URL+"/2.mp3", server.URL+"/3.mp3"
+	memory.Fetch(context.Background(), third)
+	memory.Fetch(context.Background(), first)
+
+	// The song to resume and the next one are pinned as soon as the
+	// playlist loads, before any song plays
+	player.currentIndex = 1
+	player.setPlaylist([]*types.Song{{URL: first}, {URL: second}, {URL: third}})
+	memory.Fetch(context.Background(), second)
+	if !memory.Has(second) || !memory.Has(third) || memory.Has(first) {
+		t.Error("Expected the current and next songs to stay cached")
+	}
+}
+
+func TestLiveStream(t *testing.T) {
+	metadata := "StreamTitle='Band - Song';"
+	block := append([]byte{byte((len(metadata) + 15) / 16)}, metadata...)
+	block = append(block, make([]byte, int(block[0])*16-len(metadata))...)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Icy-MetaData") != "1" {
+			t.Error("Expected the ICY metadata to be requested")
+		}
+		w.Header().Set("Icy-Metaint", "4")
+		w.Write([]byte("abcd"))
+		w.Write(block)
+		w.Write([]byte("efgh"))
+		w.Write([]byte{0})
+		w.Write([]byte("ij"))
+	}))
+	defer server.Close()
+
+	player := NewMusicPlayerWithCache(&config.PlaybackConfig{
+		FFMpegConf: &config.FFmpegConfig{},
+	}, cache.NewNopCache())
+	song := &types.Song{Kind: types.KindLive, URL: server.URL}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	url, err := player.openLive(ctx, song)
+	if err != nil {
+		t.Fatal("openLive should not return error:", err)
+	}
+	if url == server.URL {
+		t.Fatal("Expected the ICY stream to be relayed")
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal("Relay request should not return error:", err)
+	}
+	defer resp.Body.Close()
+	audio, _ := io.ReadAll(resp.Body)
+	if string(audio) != "abcdefghij" {
+		t.Errorf("Expected the metadata to be stripped, got %q", audio)
+	}
+	if song.StreamTitle != "Band - Song" {
+		t.Errorf("Expected the stream title, got %q", song.StreamTitle)
+	}
+
+	// A reconnecting backend gets a stream of its own
+	second, err := http.Get(url)
+	if err != nil {
+		t.Fatal("Second relay request should not return error:", err)
+	}
+	defer second.Body.Close()
+	if audio, _ := io.ReadAll(second.Body); string(audio) != "abcdefghij" {
+		t.Errorf("Expected the whole stream again, got %q", audio)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	player := NewMusicPlayerWithCache(&config.PlaybackConfig{
+		FFMpegConf:   &config.FFmpegConfig{},
+		WebSocketAPI: "ws://localhost:1",
+		CachePath:    t.TempDir(),
+	}, cache.NewMemoryCache(0))
+
+	player.ApplyConfig(&config.PlaybackConfig{
+		FFMpegConf:   &config.FFmpegConfig{FFPlay: "/usr/bin/ffplay"},
+		Backend:      config.BackendFFPlay,
+		WebSocketAPI: "ws://localhost:1",
+		CachePath:    player.Conf.CachePath,
+		CacheOptions: &config.CacheConfig{Type: config.CacheNone},
+	})
+	if _, ok := player.cache.(*cache.NopCache); !ok {
+		t.Errorf("Expected the new cache type, got %T", player.cache)
+	}
+	if _, ok := player.player.(*Mplayer); !ok {
+		t.Error("Expected the running backend to be kept until the next song")
+	}
+	player.switchBackends()
+	if _, ok := player.player.(*FFplay); !ok {
+		t.Errorf("Expected the new backend after switching, got %T", player.player)
+	}
+}
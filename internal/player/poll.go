@@ -0,0 +1,82 @@
+package player
+
+import (
+	"context"
+	"mmfm-playback-go/internal/httpclient"
+	"mmfm-playback-go/internal/playlist"
+	"mmfm-playback-go/pkg/types"
+	"time"
+)
+
+// LoadPlaylist loads a playlist from a web API
+func LoadPlaylist(client *httpclient.Client, apiURL string) ([]*types.Song, error) {
+	list, _, err := playlist.NewJSONSource(client, apiURL).Load(context.Background(), false)
+	return list, err
+}
+
+// loadPlaylist loads the playlist from its source. A conditional load
+// returns whether the playlist has changed since the last load, an
+// unconditional one always reports a change
+func (mp *MusicPlayer) loadPlaylist(conditional bool) ([]*types.Song, bool, error) {
+	return mp.playlistSource().Load(context.Background(), conditional)
+}
+
+// startPolling polls the playlist when an interval is configured
+func (mp *MusicPlayer) startPolling() {
+	if mp.config().PollInterval() <= 0 {
+		return
+	}
+
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	if !mp.polling {
+		mp.polling = true
+		go mp.pollPlaylist()
+	}
+}
+
+// pollPlaylist reloads the playlist every poll interval in case an update
+// message was missed, unchanged playlists are skipped. It stops once the
+// interval is removed from the configuration
+func (mp *MusicPlayer) pollPlaylist() {
+	for {
+		mp.lock.Lock()
+		interval := mp.config().PollInterval()
+		if interval <= 0 {
+			mp.polling = false
+			mp.lock.Unlock()
+			return
+		}
+		mp.lock.Unlock()
+		time.Sleep(interval)
+
+		mp.lock.Lock()
+		offline := mp.offline
+		mp.lock.Unlock()
+		if offline {
+			// reconnect reloads the playlist once the web API is back
+			continue
+		}
+
+		list, changed, err := mp.loadPlaylist(true)
+		if err != nil {
+			Logger.Error(err)
+			mp.goOffline()
+			continue
+		}
+		if !changed {
+			Logger.Debug("playlist unchanged")
+			continue
+		}
+
+		Logger.Info("playlist changed, updating")
+		mp.updatePlaylist(list)
+	}
+}
+
+// updatePlaylist switches to a new playlist from the web API
+func (mp *MusicPlayer) updatePlaylist(list []*types.Song) {
+	mp.savePlaylist(list)
+	mp.setPlaylist(list)
+	mp.prefetch()
+}
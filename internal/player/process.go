@@ -0,0 +1,202 @@
+package player
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// pidFileName is the name of the running backends file inside the cache path
+const pidFileName = "backends.pid"
+
+// stderrTail is how many bytes of the stderr of a backend an error reports
+const stderrTail = 1024
+
+// process is a backend child process. It runs in its own process group so
+// stopping it also stops the programs it started, its stderr is logged and
+// the end of it is kept for error reports
+type process struct {
+	name    string
+	cmd     *exec.Cmd
+	stderr  *stderrLog
+	stopped atomic.Bool
+}
+
+// startProcess starts cmd in its own process group and records it in the
+// pid file
+func startProcess(cmd *exec.Cmd) (*process, error) {
+	p := &process{
+		name:   filepath.Base(cmd.Path),
+		cmd:    cmd,
+		stderr: &stderrLog{name: filepath.Base(cmd.Path)},
+	}
+	cmd.Stderr = p.stderr
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	processes.add(cmd.Process.Pid, cmd.Path)
+	return p, nil
+}
+
+// wait waits for the process to exit. A non-zero exit status or a signal
+// which was not sent by stop is an error carrying the end of stderr
+func (p *process) wait() error {
+	err := p.cmd.Wait()
+	processes.remove(p.cmd.Process.Pid)
+	if err == nil || p.stopped.Load() {
+		return nil
+	}
+	if tail := p.stderr.tail(); tail != "" {
+		return fmt.Errorf("%s %v: %s", p.name, err, tail)
+	}
+	return fmt.Errorf("%s %v", p.name, err)
+}
+
+// stop kills the process group
+func (p *process) stop() {
+	p.stopped.Store(true)
+	if err := killProcessGroup(p.cmd.Process.Pid); err != nil {
+		p.cmd.Process.Kill()
+	}
+}
+
+// stderrLog logs the stderr lines of a backend and keeps the end of it
+type stderrLog struct {
+	name    string
+	line    []byte
+	written []byte
+	lock    sync.Mutex
+}
+
+// Write implements io.Writer
+func (sl *stderrLog) Write(p []byte) (int, error) {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+
+	sl.written = append(sl.written, p...)
+	if len(sl.written) > stderrTail {
+		sl.written = sl.written[len(sl.written)-stderrTail:]
+	}
+
+	sl.line = append(sl.line, p...)
+	for {
+		i := bytes.IndexAny(sl.line, "\r\n")
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(sl.line[:i])); line != "" {
+			Logger.Warningf("%s: %s", sl.name, line)
+		}
+		sl.line = sl.line[i+1:]
+	}
+	return len(p), nil
+}
+
+// tail returns the last lines written
+func (sl *stderrLog) tail() string {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	return strings.TrimSpace(string(sl.written))
+}
+
+// processes records the running backends of the daemon
+var processes = &pidRegistry{pids: map[int]string{}}
+
+// pidRegistry keeps the pid file listing the running backends up to date,
+// a crashed daemon leaves it behind for the next start to reap them
+type pidRegistry struct {
+	path string
+	pids map[int]string
+	lock sync.Mutex
+}
+
+// pidEntry is a backend in the pid file
+type pidEntry struct {
+	PID int    `json:"pid"`
+	Bin string `json:"bin"`
+}
+
+// setPath starts writing the pid file at path
+func (r *pidRegistry) setPath(path string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.path = path
+	r.save()
+}
+
+// add records a started backend
+func (r *pidRegistry) add(pid int, bin string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pids[pid] = bin
+	r.save()
+}
+
+// remove forgets an exited backend
+func (r *pidRegistry) remove(pid int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.pids, pid)
+	r.save()
+}
+
+// save writes the pid file, the lock must be held
+func (r *pidRegistry) save() {
+	if r.path == "" {
+		return
+	}
+	if len(r.pids) == 0 {
+		os.Remove(r.path)
+		return
+	}
+
+	entries := make([]pidEntry, 0, len(r.pids))
+	for pid, bin := range r.pids {
+		entries = append(entries, pidEntry{PID: pid, Bin: bin})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].PID < entries[j].PID
+	})
+	content, err := json.Marshal(entries)
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+	os.MkdirAll(filepath.Dir(r.path), 0777)
+	if err := os.WriteFile(r.path, content, 0644); err != nil {
+		Logger.Error(err)
+	}
+}
+
+// reapOrphans kills the backends listed in the pid file at path, they were
+// left behind by a run which crashed. Processes running another program are
+// left alone, their pid has been reused
+func reapOrphans(path string) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var entries []pidEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		Logger.Error(err)
+	}
+
+	for _, entry := range entries {
+		if !isBackendProcess(entry.PID, entry.Bin) {
+			continue
+		}
+		Logger.Warningf("killing %s (pid %d) left behind by a previous run", filepath.Base(entry.Bin), entry.PID)
+		if err := killProcessGroup(entry.PID); err != nil {
+			Logger.Error(err)
+		}
+	}
+	os.Remove(path)
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package player
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcessGroup makes cmd the leader of a new process group
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the process group led by pid
+func killProcessGroup(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
+
+// isBackendProcess checks if pid is a running instance of bin
+func isBackendProcess(pid int, bin string) bool {
+	if pid <= 0 || syscall.Kill(pid, 0) != nil {
+		return false
+	}
+
+	name := filepath.Base(bin)
+	if cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		arg0, _, _ := strings.Cut(string(cmdline), "\x00")
+		return filepath.Base(arg0) == name
+	}
+	// Systems without procfs, e.g. macOS
+	output, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "comm=").Output()
+	return err == nil && filepath.Base(strings.TrimSpace(string(output))) == name
+}
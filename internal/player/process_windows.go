@@ -0,0 +1,34 @@
+//go:build windows
+
+package player
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcessGroup makes cmd the root of a new process group
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// killProcessGroup kills pid with the processes it started
+func killProcessGroup(pid int) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run()
+}
+
+// isBackendProcess checks if pid is a running instance of bin
+func isBackendProcess(pid int, bin string) bool {
+	if pid <= 0 {
+		return false
+	}
+	output, err := exec.Command("tasklist", "/FI", "PID eq "+strconv.Itoa(pid), "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return false
+	}
+	name := strings.TrimSuffix(strings.ToLower(filepath.Base(bin)), ".exe")
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(string(output))), `"`+name)
+}
@@ -0,0 +1,129 @@
+package player
+
+import (
+	"mmfm-playback-go/internal/config"
+	"reflect"
+)
+
+// ApplyConfig switches to conf without interrupting the playback. Changed
+// backends take over from the next song on, the chat reconnects only when
+// the WebSocket address changed and the playlist is reloaded when its source
+// changed
+func (mp *MusicPlayer) ApplyConfig(conf *config.PlaybackConfig) {
+	mp.settings.Lock()
+	old, client := mp.Conf, mp.client
+	mp.Conf = conf
+	mp.settings.Unlock()
+	Logger.Info("configuration reloaded")
+
+	// The web API host is trusted with the HTTP headers
+	httpChanged := !reflect.DeepEqual(old.HTTP, conf.HTTP) || old.WebAPI != conf.WebAPI
+	if httpChanged {
+		client = newClient(conf)
+	}
+
+	// A changed audio config replaces the output chosen by player.output
+	audioChanged := !reflect.DeepEqual(old.Audio, conf.Audio)
+	if audioChanged {
+		mp.lock.Lock()
+		mp.output = config.AudioConfig{}
+		if conf.Audio != nil {
+			mp.output = *conf.Audio
+		}
+		mp.lock.Unlock()
+	}
+
+	if httpChanged || audioChanged || old.Backend != conf.Backend || !reflect.DeepEqual(old.FFMpegConf, conf.FFMpegConf) {
+		Logger.Info("backends changed, switching with the next song")
+		player, fader := NewBackend(conf), NewBackend(conf)
+		mp.lock.Lock()
+		output := mp.output
+		mp.lock.Unlock()
+		for _, backend := range []Backend{player, fader} {
+			backend.SetClient(client)
+			backend.SetOutput(output.Driver, output.Device)
+		}
+		probe := NewProber(conf)
+		probe.SetClient(client)
+
+		mp.lock.Lock()
+		mp.nextPlayer, mp.nextFader = player, fader
+		mp.lock.Unlock()
+		mp.settings.Lock()
+		mp.probe = probe
+		mp.settings.Unlock()
+	}
+
+	sourceChanged := httpChanged || old.WebAPI != conf.WebAPI || old.PlaylistType != conf.PlaylistType ||
+		old.PlaylistFallback != conf.PlaylistFallback
+	if sourceChanged {
+		source := newSource(conf, client)
+		mp.settings.Lock()
+		mp.client, mp.source = client, source
+		mp.settings.Unlock()
+	}
+
+	if httpChanged || old.CachePath != conf.CachePath || !reflect.DeepEqual(old.CacheOptions, conf.CacheOptions) ||
+		!reflect.DeepEqual(old.Loudness, conf.Loudness) {
+		Logger.Info("cache changed, switching to the new cache")
+		c, err := newCache(conf)
+		if err != nil {
+			Logger.Error(err, ", keeping the current cache")
+		} else {
+			mp.setCache(c)
+			mp.lock.Lock()
+			list := mp.playlist
+			mp.lock.Unlock()
+			mp.registerSongs(list)
+		}
+	}
+
+	if old.WebSocketAPI != conf.WebSocketAPI {
+		Logger.Info("chat server changed, reconnecting to", conf.WebSocketAPI)
+		mp.chat.SetURL(conf.WebSocketAPI)
+	}
+	if old.API != conf.API {
+		Logger.Warning("the status API address applies after a restart")
+	}
+
+	if !reflect.DeepEqual(old.VolumeSchedules, conf.VolumeSchedules) {
+		// The volume of the current window is applied again
+		mp.lock.Lock()
+		mp.volumeSchedule = -1
+		mp.lock.Unlock()
+	}
+	if mp.isStarted() {
+		mp.startScheduler()
+		mp.startPolling()
+	}
+
+	if sourceChanged {
+		go func() {
+			list, _, err := mp.loadPlaylist(false)
+			if err != nil {
+				Logger.Error(err)
+				return
+			}
+			mp.updatePlaylist(list)
+		}()
+		return
+	}
+	// New scheduled audios have to be cached before their time
+	mp.pinCache()
+	mp.prefetch()
+}
+
+// switchBackends puts the backends of a reload into use, it is called
+// before a playback starts so the running one is not interrupted
+func (mp *MusicPlayer) switchBackends() {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	if mp.nextPlayer == nil {
+		return
+	}
+
+	mp.player.Stop()
+	mp.fader.Stop()
+	mp.player, mp.fader = mp.nextPlayer, mp.nextFader
+	mp.nextPlayer, mp.nextFader = nil, nil
+}
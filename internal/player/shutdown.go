@@ -0,0 +1,128 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"mmfm-playback-go/internal/cache"
+	"mmfm-playback-go/internal/config"
+	"time"
+)
+
+// chatFlushDelay gives the final pause event time to be sent, the chat
+// connection discards queued messages when it is closed
+const chatFlushDelay = 300 * time.Millisecond
+
+// errStopping is returned by playbacks requested during the shutdown
+var errStopping = errors.New("player is shutting down")
+
+// Run starts the player and shuts it down once ctx is done
+func (mp *MusicPlayer) Run(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- mp.Start()
+	}()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			return err
+		}
+		<-ctx.Done()
+	case <-ctx.Done():
+	}
+	mp.Shutdown()
+	return nil
+}
+
+// Shutdown stops the backends, sends a final pause event, closes the chat
+// and saves the position so the next start resumes from it. No playback
+// starts afterwards
+func (mp *MusicPlayer) Shutdown() {
+	// The play mode to resume is the one the listeners asked for, not the
+	// pause of a scheduled audio or of the operating hours
+	mp.lock.Lock()
+	mp.stopping = true
+	paused := mp.pauseFlag
+	if mp.scheduledAudioPlaying {
+		paused = mp.originalPaused
+	}
+	if mp.closed {
+		paused = !mp.resumeOnOpen
+	}
+	mp.pauseFlag = true
+	mp.lock.Unlock()
+	Logger.Info("shutting down")
+
+	mp.stopPlayback()
+	if prefetcher := mp.cachePrefetcher(); prefetcher != nil {
+		prefetcher.Stop()
+	}
+	if fc, ok := mp.Cache().(*cache.FileCache); ok {
+		fc.StopEviction()
+	}
+
+	mp.savePlayback(paused)
+	mp.FirePause()
+	time.Sleep(chatFlushDelay)
+	mp.chat.Close()
+	Logger.Info("player stopped")
+}
+
+// isStopping checks if the player is shutting down
+func (mp *MusicPlayer) isStopping() bool {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	return mp.stopping
+}
+
+// savePlayback persists the current song and position
+func (mp *MusicPlayer) savePlayback(paused bool) {
+	mp.lock.Lock()
+	if mp.currentSong == nil {
+		mp.lock.Unlock()
+		return
+	}
+	mp.state.Playback = &Playback{
+		Index:    int(mp.currentIndex),
+		URL:      mp.currentSong.GetURL(),
+		Position: mp.currentSong.Index,
+		Paused:   paused,
+		SavedAt:  time.Now(),
+	}
+	mp.lock.Unlock()
+	mp.saveState()
+}
+
+// restorePlayback moves to the song saved by the last run and returns the
+// second to start it from and whether it was paused. The song is found by
+// its URL, the saved index is used from its start when the playlist no
+// longer contains it
+func (mp *MusicPlayer) restorePlayback() (int, bool) {
+	resume := mp.config().Resume
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	saved := mp.state.Playback
+	if saved == nil || resume == config.ResumeOff || len(mp.playlist) == 0 {
+		return 0, false
+	}
+
+	for i, song := range mp.playlist {
+		if song.GetURL() != saved.URL {
+			continue
+		}
+		mp.currentIndex = float64(i)
+		second := 0
+		if resume != config.ResumeSong && !song.IsLive() {
+			second = int(saved.Position)
+		}
+		Logger.Infof("resuming %s at %d seconds", song.Name, second)
+		return second, saved.Paused
+	}
+
+	if saved.Index < len(mp.playlist) {
+		mp.currentIndex = float64(saved.Index)
+		Logger.Info("saved song is no longer in the playlist, resuming at index", saved.Index)
+		return 0, saved.Paused
+	}
+	return 0, false
+}
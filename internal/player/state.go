@@ -0,0 +1,56 @@
+package player
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateFile is the name of the persisted player state inside the cache path
+const stateFile = "state.json"
+
+// State holds the player settings which survive a restart
+type State struct {
+	Volume   int       `json:"volume"`
+	Muted    bool      `json:"muted"`
+	Playback *Playback `json:"playback,omitempty"`
+}
+
+// Playback is the position in the playlist the next start resumes from
+type Playback struct {
+	Index    int       `json:"index"`
+	URL      string    `json:"url"`
+	Position float64   `json:"position"` // Seconds into the song
+	Paused   bool      `json:"paused"`
+	SavedAt  time.Time `json:"saved_at"`
+}
+
+// loadState reads the persisted state, a missing or broken file yields the
+// default state
+func loadState(basePath string) *State {
+	state := &State{
+		Volume: defaultVolume,
+	}
+
+	content, err := os.ReadFile(filepath.Join(basePath, stateFile))
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(content, state); err != nil {
+		Logger.Error(err)
+	}
+	return state
+}
+
+// save writes the state into the cache path
+func (s *State) save(basePath string) error {
+	content, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(basePath, 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(basePath, stateFile), content, 0644)
+}
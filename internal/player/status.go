@@ -0,0 +1,95 @@
+package player
+
+import (
+	"mmfm-playback-go/internal/cache"
+	"mmfm-playback-go/internal/chat"
+	"mmfm-playback-go/pkg/types"
+)
+
+// Status is the player state exposed by the status API
+type Status struct {
+	Song     *types.Song     `json:"song"`
+	Index    float64         `json:"index"`
+	Position float64         `json:"position"`
+	Duration float64         `json:"duration"`
+	Paused   bool            `json:"paused"`
+	Volume   int             `json:"volume"`
+	Muted    bool            `json:"muted"`
+	Offline  bool            `json:"offline"`
+	Closed   bool            `json:"closed"`
+	Playlist int             `json:"playlist"`
+	Output   string          `json:"output,omitempty"` // driver:device, empty for the default output
+	Prefetch *cache.Progress `json:"prefetch,omitempty"`
+}
+
+// Status returns the current player state, the song is a copy so it can be
+// encoded while the position advances
+func (mp *MusicPlayer) Status() *Status {
+	mp.lock.Lock()
+	status := &Status{
+		Index:    mp.currentIndex,
+		Paused:   mp.pauseFlag,
+		Volume:   mp.state.Volume,
+		Muted:    mp.state.Muted,
+		Offline:  mp.offline,
+		Closed:   mp.closed,
+		Playlist: len(mp.playlist),
+		Output:   AudioDevice{Driver: mp.output.Driver, Device: mp.output.Device}.Spec(),
+	}
+	if mp.currentSong != nil {
+		song := *mp.currentSong
+		status.Song = &song
+		status.Position = song.Index
+		status.Duration = song.Duration
+	}
+	mp.lock.Unlock()
+	if prefetcher := mp.cachePrefetcher(); prefetcher != nil {
+		progress := prefetcher.Progress()
+		status.Prefetch = &progress
+	}
+	return status
+}
+
+// prefetch downloads the playlist and the scheduled audios in background,
+// the songs up next come first
+func (mp *MusicPlayer) prefetch() {
+	prefetcher := mp.cachePrefetcher()
+	if prefetcher == nil {
+		return
+	}
+	scheduledAudios := mp.config().ScheduledAudios
+
+	mp.lock.Lock()
+	playlist, index := mp.playlist, int(mp.currentIndex)
+	mp.lock.Unlock()
+
+	keys := []string{}
+	count := len(playlist)
+	for i := 1; i <= count; i++ {
+		if song := playlist[(index+i)%count]; !song.IsLive() {
+			keys = append(keys, song.GetURL())
+		}
+		if i == 1 {
+			// Scheduled audios have to be ready at their time
+			for _, scheduledAudio := range scheduledAudios {
+				keys = append(keys, scheduledAudio.URL)
+			}
+		}
+	}
+	if count == 0 {
+		for _, scheduledAudio := range scheduledAudios {
+			keys = append(keys, scheduledAudio.URL)
+		}
+	}
+	prefetcher.Prefetch(keys)
+}
+
+// firePrefetchProgress broadcasts the progress of the prefetcher
+func (mp *MusicPlayer) firePrefetchProgress(progress cache.Progress) {
+	if mp.chat != nil {
+		mp.chat.SendEvent(chat.CHAT_EVENT_MESSAGE, &chat.MessageArgs{
+			Command: chat.EVENT_CACHE_PROGRESS,
+			Params:  []interface{}{progress},
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package player
+
+import (
+	"context"
+	"mmfm-playback-go/internal/cache"
+	"mmfm-playback-go/pkg/types"
+)
+
+// Cache returns the cache storing the songs
+func (mp *MusicPlayer) Cache() cache.Cache {
+	mp.settings.RLock()
+	defer mp.settings.RUnlock()
+	return mp.cache
+}
+
+// LoadSongs loads the playlist from the configured source without playing
+// it
+func (mp *MusicPlayer) LoadSongs(ctx context.Context) ([]*types.Song, error) {
+	list, _, err := mp.playlistSource().Load(ctx, false)
+	return list, err
+}
+
+// Probe reads the media details of url with the prober of the configured
+// backend and the HTTP headers
+func (mp *MusicPlayer) Probe(url string) (*MediaDetails, error) {
+	info, err := mp.prober().GetMediaInfo(url)
+	if err != nil {
+		return nil, err
+	}
+	return info.Details(), nil
+}
+
+// PlayOnce plays url with the configured backend at the saved volume until it ends
+// or ctx is done, the playlist and the cache are not involved
+func (mp *MusicPlayer) PlayOnce(ctx context.Context, url string) error {
+	mp.player.SetVolume(mp.outputVolume())
+	finish, err := mp.player.Play(url, 0)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-finish:
+		return err
+	case <-ctx.Done():
+		mp.player.Stop()
+		return nil
+	}
+}
@@ -0,0 +1,159 @@
+package player
+
+import (
+	"fmt"
+	"mmfm-playback-go/internal/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultVolume is the volume of a fresh installation
+const defaultVolume = 100
+
+// outputVolume returns the volume the backend should play at
+func (mp *MusicPlayer) outputVolume() int {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	if mp.state.Muted {
+		return 0
+	}
+	return mp.state.Volume
+}
+
+// volume returns the volume and the muted state
+func (mp *MusicPlayer) volume() (int, bool) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+	return mp.state.Volume, mp.state.Muted
+}
+
+// saveState persists a copy of the state taken under the lock
+func (mp *MusicPlayer) saveState() {
+	mp.lock.Lock()
+	state := *mp.state
+	mp.lock.Unlock()
+	if err := state.save(mp.config().CachePath); err != nil {
+		Logger.Error(err)
+	}
+}
+
+// SetVolume changes the volume (0-100) and persists it
+func (mp *MusicPlayer) SetVolume(volume int) {
+	if volume < 0 {
+		volume = 0
+	}
+	if volume > 100 {
+		volume = 100
+	}
+	mp.lock.Lock()
+	mp.state.Volume = volume
+	mp.lock.Unlock()
+	mp.applyVolume()
+}
+
+// Mute silences the output without losing the volume
+func (mp *MusicPlayer) Mute() {
+	mp.lock.Lock()
+	mp.state.Muted = true
+	mp.lock.Unlock()
+	mp.applyVolume()
+}
+
+// Unmute restores the volume after Mute
+func (mp *MusicPlayer) Unmute() {
+	mp.lock.Lock()
+	mp.state.Muted = false
+	mp.lock.Unlock()
+	mp.applyVolume()
+}
+
+// applyVolume forwards the volume to the backend, persists it and
+// broadcasts the new state
+func (mp *MusicPlayer) applyVolume() {
+	volume, muted := mp.volume()
+	Logger.Infof("volume %d, muted %v", volume, muted)
+	if err := mp.player.SetVolume(mp.outputVolume()); err != nil {
+		Logger.Error(err)
+	}
+	mp.saveState()
+	mp.FireCurrent()
+}
+
+// applyVolumeSchedule ramps to the volume of the schedule active at now,
+// the volume is only touched when the active schedule changes so manual
+// changes are kept until the next boundary
+func (mp *MusicPlayer) applyVolumeSchedule(now time.Time) {
+	conf := mp.config()
+	active := -1
+	for i := range conf.VolumeSchedules {
+		if conf.VolumeSchedules[i].Contains(now) {
+			active = i
+			break
+		}
+	}
+	if active == mp.volumeSchedule {
+		return
+	}
+	mp.volumeSchedule = active
+	if active < 0 {
+		return
+	}
+
+	schedule := conf.VolumeSchedules[active]
+	Logger.Infof("volume schedule from %s, volume %d", schedule.Start, schedule.Volume)
+	go mp.rampVolume(schedule.Volume, time.Duration(conf.VolumeRamp*float64(time.Second)))
+}
+
+// rampVolume moves the volume to target in one second steps, the ramp stops
+// when the volume is changed by someone else in the meantime
+func (mp *MusicPlayer) rampVolume(target int, duration time.Duration) {
+	steps := int(duration / time.Second)
+	if steps < 1 || mp.config().Backend == config.BackendFFPlay {
+		mp.SetVolume(target)
+		return
+	}
+
+	from, _ := mp.volume()
+	expected := from
+	for i := 1; i <= steps; i++ {
+		time.Sleep(time.Second)
+		mp.lock.Lock()
+		changed := mp.state.Volume != expected
+		if !changed && i < steps {
+			expected = from + (target-from)*i/steps
+			mp.state.Volume = expected
+		}
+		mp.lock.Unlock()
+		if changed {
+			Logger.Debug("volume ramp interrupted")
+			return
+		}
+		if i == steps {
+			break
+		}
+		mp.player.SetVolume(mp.outputVolume())
+	}
+	mp.SetVolume(target)
+}
+
+// parseVolume resolves the parameter of a player.volume command, numbers
+// and plain strings are absolute while strings with a sign are relative to
+// the current volume
+func parseVolume(param interface{}, current int) (int, error) {
+	switch value := param.(type) {
+	case float64:
+		return int(value), nil
+	case string:
+		value = strings.TrimSpace(value)
+		volume, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid volume %q", value)
+		}
+		if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+			return current + volume, nil
+		}
+		return volume, nil
+	}
+	return 0, fmt.Errorf("invalid volume %v", param)
+}
@@ -0,0 +1,99 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"mmfm-playback-go/internal/logger"
+	"mmfm-playback-go/pkg/types"
+	"path/filepath"
+	"strings"
+)
+
+// audioExtensions are the files picked up by a DirectorySource
+var audioExtensions = map[string]bool{
+	".mp3":  true,
+	".m4a":  true,
+	".aac":  true,
+	".flac": true,
+	".ogg":  true,
+	".opus": true,
+	".wav":  true,
+	".wma":  true,
+}
+
+// DirectorySource plays the audio files of a local directory, e.g. a music
+// folder on a USB stick. Names, authors and durations are read from the tags
+// of the files when a prober is available
+type DirectorySource struct {
+	path    string
+	prober  Prober
+	tracker tracker
+}
+
+// NewDirectorySource creates a new DirectorySource instance
+func NewDirectorySource(path string, prober Prober) *DirectorySource {
+	return &DirectorySource{
+		path:   path,
+		prober: prober,
+	}
+}
+
+// Load implements Source, the directory is scanned recursively in lexical
+// order
+func (ds *DirectorySource) Load(ctx context.Context, conditional bool) ([]*types.Song, bool, error) {
+	var files []string
+	err := filepath.WalkDir(ds.path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !entry.IsDir() && audioExtensions[strings.ToLower(filepath.Ext(path))] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Probing is skipped while the files stay the same
+	listing, _ := json.Marshal(files)
+	if !ds.tracker.changed(listing) && conditional {
+		return nil, false, nil
+	}
+
+	list := make([]*types.Song, 0, len(files))
+	for _, file := range files {
+		song := &types.Song{
+			URL:  file,
+			Name: titleOf(file),
+		}
+		if ds.prober != nil {
+			ds.probe(song)
+		}
+		list = append(list, song)
+	}
+	return list, true, nil
+}
+
+// probe fills a song from the tags of its file
+func (ds *DirectorySource) probe(song *types.Song) {
+	tags, duration, err := ds.prober.GetTags(song.URL)
+	if err != nil {
+		logger.Logger.Debug("cannot read the tags of", song.URL, err)
+		return
+	}
+	if title := tags["title"]; title != "" {
+		song.Name = title
+	}
+	for _, tag := range []string{"artist", "album_artist"} {
+		if author := tags[tag]; author != "" {
+			song.Author = author
+			break
+		}
+	}
+	song.Duration = duration
+}
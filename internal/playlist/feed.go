@@ -0,0 +1,181 @@
+package playlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"mmfm-playback-go/internal/httpclient"
+	"mmfm-playback-go/pkg/types"
+	"strconv"
+	"strings"
+)
+
+// rssFeed is the part of an RSS 2.0 podcast feed used for playlists
+type rssFeed struct {
+	Channel struct {
+		Author string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+		Items  []struct {
+			Title     string `xml:"title"`
+			GUID      string `xml:"guid"`
+			Author    string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+			Duration  string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+			Enclosure struct {
+				URL string `xml:"url,attr"`
+			} `xml:"enclosure"`
+			Image struct {
+				Href string `xml:"href,attr"`
+			} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// atomFeed is the part of an Atom feed used for playlists
+type atomFeed struct {
+	Author struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Entries []struct {
+		ID     string `xml:"id"`
+		Title  string `xml:"title"`
+		Author struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// FeedSource plays the episodes of an RSS or Atom podcast feed, entries
+// without audio enclosure are skipped
+type FeedSource struct {
+	client   *httpclient.Client
+	location string
+	tracker  tracker
+}
+
+// NewFeedSource creates a new FeedSource instance
+func NewFeedSource(client *httpclient.Client, location string) *FeedSource {
+	return &FeedSource{
+		client:   client,
+		location: location,
+	}
+}
+
+// Load implements Source
+func (fs *FeedSource) Load(ctx context.Context, conditional bool) ([]*types.Song, bool, error) {
+	content, err := read(ctx, fs.client, fs.location)
+	if err != nil {
+		return nil, false, err
+	}
+	if !fs.tracker.changed(content) && conditional {
+		return nil, false, nil
+	}
+
+	list, err := parseFeed(content)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, song := range list {
+		song.URL = resolve(fs.location, song.URL)
+	}
+	return list, true, nil
+}
+
+// parseFeed parses an RSS or Atom feed depending on its root element
+func parseFeed(content []byte) ([]*types.Song, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid feed: %w", err)
+		}
+		root, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch root.Name.Local {
+		case "rss":
+			return parseRSS(content)
+		case "feed":
+			return parseAtom(content)
+		}
+		return nil, fmt.Errorf("unsupported feed element: %s", root.Name.Local)
+	}
+}
+
+// parseRSS lists the enclosures of an RSS feed
+func parseRSS(content []byte) ([]*types.Song, error) {
+	feed := &rssFeed{}
+	if err := xml.Unmarshal(content, feed); err != nil {
+		return nil, err
+	}
+
+	list := []*types.Song{}
+	for _, item := range feed.Channel.Items {
+		if item.Enclosure.URL == "" {
+			continue
+		}
+		song := &types.Song{
+			URL:      item.Enclosure.URL,
+			Name:     strings.TrimSpace(item.Title),
+			Author:   item.Author,
+			Cover:    item.Image.Href,
+			Duration: parseDuration(item.Duration),
+		}
+		if song.Author == "" {
+			song.Author = feed.Channel.Author
+		}
+		if item.GUID != "" {
+			song.ID = item.GUID
+		}
+		list = append(list, song)
+	}
+	return list, nil
+}
+
+// parseAtom lists the enclosure links of an Atom feed
+func parseAtom(content []byte) ([]*types.Song, error) {
+	feed := &atomFeed{}
+	if err := xml.Unmarshal(content, feed); err != nil {
+		return nil, err
+	}
+
+	list := []*types.Song{}
+	for _, entry := range feed.Entries {
+		for _, link := range entry.Links {
+			if link.Rel != "enclosure" || link.Href == "" {
+				continue
+			}
+			song := &types.Song{
+				URL:    link.Href,
+				Name:   strings.TrimSpace(entry.Title),
+				Author: entry.Author.Name,
+			}
+			if song.Author == "" {
+				song.Author = feed.Author.Name
+			}
+			if entry.ID != "" {
+				song.ID = entry.ID
+			}
+			list = append(list, song)
+			break
+		}
+	}
+	return list, nil
+}
+
+// parseDuration parses an itunes:duration in seconds, MM:SS or HH:MM:SS
+func parseDuration(duration string) float64 {
+	var seconds float64
+	for _, part := range strings.Split(strings.TrimSpace(duration), ":") {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds
+}
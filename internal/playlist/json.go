@@ -0,0 +1,89 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/httpclient"
+	"mmfm-playback-go/internal/logger"
+	"mmfm-playback-go/pkg/types"
+	"net/http"
+	"sync"
+)
+
+// JSONSource loads the JSON array of songs of the MMFM web API, polls are
+// conditional on the ETag and Last-Modified of the previous response
+type JSONSource struct {
+	client       *httpclient.Client
+	url          string
+	etag         string
+	lastModified string
+	tracker      tracker
+	lock         sync.Mutex
+}
+
+// NewJSONSource creates a new JSONSource instance
+func NewJSONSource(client *httpclient.Client, url string) *JSONSource {
+	return &JSONSource{
+		client: client,
+		url:    url,
+	}
+}
+
+// Load implements Source
+func (js *JSONSource) Load(ctx context.Context, conditional bool) ([]*types.Song, bool, error) {
+	logger.Logger.Info("Loading playlist from", js.url)
+	req, err := http.NewRequest(http.MethodGet, js.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if conditional {
+		js.lock.Lock()
+		if js.etag != "" {
+			req.Header.Set("If-None-Match", js.etag)
+		}
+		if js.lastModified != "" {
+			req.Header.Set("If-Modified-Since", js.lastModified)
+		}
+		js.lock.Unlock()
+	}
+
+	if timeout := js.client.Timeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	resp, err := js.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("playlist API returned status %d", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	var playlist []*types.Song
+	if err := json.Unmarshal(content, &playlist); err != nil {
+		return nil, false, err
+	}
+	logger.Logger.Debug("Loaded playlist:", playlist)
+
+	js.lock.Lock()
+	js.etag = resp.Header.Get("ETag")
+	js.lastModified = resp.Header.Get("Last-Modified")
+	js.lock.Unlock()
+
+	// Web APIs without validators answer every poll with the full playlist
+	if !js.tracker.changed(content) && conditional {
+		return nil, false, nil
+	}
+	return playlist, true, nil
+}
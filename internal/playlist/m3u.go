@@ -0,0 +1,184 @@
+package playlist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"mmfm-playback-go/internal/httpclient"
+	"mmfm-playback-go/pkg/types"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// M3USource loads M3U, M3U8 and PLS playlists from a URL or a local file
+type M3USource struct {
+	client   *httpclient.Client
+	location string
+	tracker  tracker
+}
+
+// NewM3USource creates a new M3USource instance
+func NewM3USource(client *httpclient.Client, location string) *M3USource {
+	return &M3USource{
+		client:   client,
+		location: location,
+	}
+}
+
+// Load implements Source
+func (ms *M3USource) Load(ctx context.Context, conditional bool) ([]*types.Song, bool, error) {
+	content, err := read(ctx, ms.client, ms.location)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ms.tracker.changed(content) && conditional {
+		return nil, false, nil
+	}
+
+	var list []*types.Song
+	if bytes.HasPrefix(bytes.TrimSpace(bytes.ToLower(content)), []byte("[playlist]")) {
+		list = parsePLS(content)
+	} else {
+		list = parseM3U(content)
+	}
+	for _, song := range list {
+		song.URL = resolve(ms.location, song.URL)
+	}
+	return list, true, nil
+}
+
+// parseM3U parses an extended or plain M3U playlist
+func parseM3U(content []byte) []*types.Song {
+	list := []*types.Song{}
+	var info *types.Song
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info = &types.Song{}
+			duration, title, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			fields := strings.Fields(duration)
+			if len(fields) > 0 {
+				if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil && seconds > 0 {
+					info.Duration = seconds
+				}
+			}
+			setTitle(info, title)
+		case strings.HasPrefix(line, "#"):
+		default:
+			song := info
+			if song == nil {
+				song = &types.Song{Name: titleOf(line)}
+			}
+			song.URL = line
+			list = append(list, song)
+			info = nil
+		}
+	}
+	return list
+}
+
+// parsePLS parses a PLS playlist, entries are ordered by their number
+func parsePLS(content []byte) []*types.Song {
+	entries := map[int]*types.Song{}
+	entry := func(number int) *types.Song {
+		if _, ok := entries[number]; !ok {
+			entries[number] = &types.Song{}
+		}
+		return entries[number]
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !found {
+			continue
+		}
+		lower := strings.ToLower(key)
+		for _, field := range []string{"file", "title", "length"} {
+			if !strings.HasPrefix(lower, field) {
+				continue
+			}
+			number, err := strconv.Atoi(lower[len(field):])
+			if err != nil {
+				continue
+			}
+			switch field {
+			case "file":
+				entry(number).URL = value
+			case "title":
+				setTitle(entry(number), value)
+			case "length":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					entry(number).Duration = seconds
+				}
+			}
+		}
+	}
+
+	numbers := make([]int, 0, len(entries))
+	for number, song := range entries {
+		if song.URL != "" {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+
+	list := make([]*types.Song, 0, len(numbers))
+	for _, number := range numbers {
+		song := entries[number]
+		if song.Name == "" {
+			song.Name = titleOf(song.URL)
+		}
+		list = append(list, song)
+	}
+	return list
+}
+
+// setTitle fills the name and author of a "Author - Name" title
+func setTitle(song *types.Song, title string) {
+	title = strings.TrimSpace(title)
+	if author, name, found := strings.Cut(title, " - "); found {
+		song.Author = strings.TrimSpace(author)
+		song.Name = strings.TrimSpace(name)
+		return
+	}
+	song.Name = title
+}
+
+// titleOf derives a title from the file name of a location
+func titleOf(location string) string {
+	if u, err := url.Parse(location); err == nil && u.Path != "" {
+		location = u.Path
+	}
+	name := filepath.Base(location)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// resolve makes an entry relative to the playlist absolute
+func resolve(base string, entry string) string {
+	if isRemote(entry) {
+		return entry
+	}
+	if isRemote(base) {
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return entry
+		}
+		ref, err := url.Parse(entry)
+		if err != nil {
+			return entry
+		}
+		return baseURL.ResolveReference(ref).String()
+	}
+	if filepath.IsAbs(entry) {
+		return entry
+	}
+	return filepath.Join(filepath.Dir(localPath(base)), filepath.FromSlash(entry))
+}
@@ -0,0 +1,184 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"mmfm-playback-go/internal/httpclient"
+	"mmfm-playback-go/pkg/types"
+	"mmfm-playback-go/tests"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONSource(t *testing.T) {
+	body := `[{"name": "first", "url": "http://mmfm/first.mp3"}]`
+	etag := `"v1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	source := NewJSONSource(httpclient.Default, server.URL)
+	list, changed, err := source.Load(context.Background(), false)
+	if err != nil || !changed || len(list) != 1 {
+		t.Fatalf("Expected the initial playlist, got %v %v %v", list, changed, err)
+	}
+	if _, changed, err := source.Load(context.Background(), true); err != nil || changed {
+		t.Errorf("Expected a not modified playlist, got %v %v", changed, err)
+	}
+
+	// Without validators the content decides
+	etag = ""
+	if _, changed, _ := source.Load(context.Background(), true); changed {
+		t.Error("Expected the same content to be unchanged")
+	}
+	body = `[{"name": "second", "url": "http://mmfm/second.mp3"}]`
+	if list, changed, _ := source.Load(context.Background(), true); !changed || list[0].Name != "second" {
+		t.Error("Expected the new content to be a change")
+	}
+}
+
+func TestM3USource(t *testing.T) {
+	dir := t.TempDir()
+	m3u := filepath.Join(dir, "list.m3u8")
+	os.WriteFile(m3u, []byte("#EXTM3U\n#EXTINF:215,Band - Song\nmusic/song.mp3\nhttp://mmfm/other.mp3\n"), 0644)
+
+	list, _, err := NewM3USource(httpclient.Default, m3u).Load(context.Background(), false)
+	if err != nil {
+		t.Fatal("Load should not return error:", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 songs, got %s", tests.ToJSON(list))
+	}
+	if list[0].Name != "Song" || list[0].Author != "Band" || list[0].Duration != 215 {
+		t.Errorf("Expected the EXTINF metadata, got %s", tests.ToJSON(list[0]))
+	}
+	if list[0].URL != filepath.Join(dir, "music", "song.mp3") || list[1].Name != "other" {
+		t.Errorf("Expected resolved entries, got %s", tests.ToJSON(list))
+	}
+
+	pls := filepath.Join(dir, "list.pls")
+	os.WriteFile(pls, []byte("[playlist]\nFile2=b.mp3\nFile1=http://mmfm/a.mp3\nTitle1=First\nLength1=60\nNumberOfEntries=2\n"), 0644)
+	list, _, err = NewM3USource(httpclient.Default, pls).Load(context.Background(), false)
+	if err != nil {
+		t.Fatal("Load should not return error:", err)
+	}
+	if len(list) != 2 || list[0].Name != "First" || list[0].Duration != 60 || list[1].URL != filepath.Join(dir, "b.mp3") {
+		t.Errorf("Expected ordered PLS entries, got %s", tests.ToJSON(list))
+	}
+}
+
+// staticProber returns the same tags for every file
+type staticProber map[string]string
+
+func (sp staticProber) GetTags(url string) (map[string]string, float64, error) {
+	return sp, 42, nil
+}
+
+func TestDirectorySource(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "b"), 0777)
+	for _, name := range []string{"b/two.mp3", "one.FLAC", "cover.jpg"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("audio"), 0644)
+	}
+
+	source := NewDirectorySource(dir, nil)
+	list, _, err := source.Load(context.Background(), false)
+	if err != nil {
+		t.Fatal("Load should not return error:", err)
+	}
+	if len(list) != 2 || list[0].Name != "two" || list[1].Name != "one" {
+		t.Errorf("Expected the audio files in lexical order, got %s", tests.ToJSON(list))
+	}
+	if _, changed, _ := source.Load(context.Background(), true); changed {
+		t.Error("Expected an unchanged directory")
+	}
+
+	list, _, _ = NewDirectorySource(dir, staticProber{"title": "Tagged", "artist": "Band"}).Load(context.Background(), false)
+	if list[0].Name != "Tagged" || list[0].Author != "Band" || list[0].Duration != 42 {
+		t.Errorf("Expected the tags to be used, got %s", tests.ToJSON(list[0]))
+	}
+}
+
+func TestFeedSource(t *testing.T) {
+	rss := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel><itunes:author>Station</itunes:author>
+<item><title>Episode 2</title><guid>ep2</guid><itunes:duration>01:02:03</itunes:duration><enclosure url="http://mmfm/ep2.mp3" type="audio/mpeg"/></item>
+<item><title>Notes only</title></item>
+</channel></rss>`
+	atom := `<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><author><name>Station</name></author>
+<entry><id>ep1</id><title>Episode 1</title><link rel="alternate" href="http://mmfm/ep1"/><link rel="enclosure" href="/ep1.mp3"/></entry>
+</feed>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed.atom" {
+			w.Write([]byte(atom))
+			return
+		}
+		w.Write([]byte(rss))
+	}))
+	defer server.Close()
+
+	list, _, err := NewFeedSource(httpclient.Default, server.URL+"/feed.rss").Load(context.Background(), false)
+	if err != nil {
+		t.Fatal("Load should not return error:", err)
+	}
+	if len(list) != 1 || list[0].Name != "Episode 2" || list[0].Author != "Station" || list[0].Duration != 3723 || list[0].GetID() != "ep2" {
+		t.Errorf("Expected the RSS enclosure, got %s", tests.ToJSON(list))
+	}
+
+	list, _, err = NewFeedSource(httpclient.Default, server.URL+"/feed.atom").Load(context.Background(), false)
+	if err != nil {
+		t.Fatal("Load should not return error:", err)
+	}
+	if len(list) != 1 || list[0].URL != server.URL+"/ep1.mp3" || list[0].Author != "Station" {
+		t.Errorf("Expected the Atom enclosure, got %s", tests.ToJSON(list))
+	}
+}
+
+// failingSource is a source which is never available
+type failingSource struct{}
+
+func (fs failingSource) Load(ctx context.Context, conditional bool) ([]*types.Song, bool, error) {
+	return nil, false, errors.New("unavailable")
+}
+
+func TestFallbackSource(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "song.mp3"), []byte("audio"), 0644)
+
+	source := NewFallbackSource(failingSource{}, NewDirectorySource(dir, nil))
+	list, changed, err := source.Load(context.Background(), true)
+	if err != nil || !changed || len(list) != 1 {
+		t.Errorf("Expected the fallback playlist, got %v %v %v", list, changed, err)
+	}
+	if _, changed, _ := source.Load(context.Background(), true); changed {
+		t.Error("Expected the unchanged fallback playlist to be skipped")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		"http://mmfm/song/get":       TypeJSON,
+		"http://radio/list.m3u8?x":   TypeM3U,
+		"https://podcast/feed.rss":   TypeFeed,
+		t.TempDir():                  TypeDirectory,
+		"file:///media/usb/list.pls": TypeM3U,
+	}
+	for location, expected := range cases {
+		if detected := Detect(location); detected != expected {
+			t.Errorf("Expected %s to be detected as %s, got %s", location, expected, detected)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package playlist
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"mmfm-playback-go/internal/httpclient"
+	"mmfm-playback-go/internal/logger"
+	"mmfm-playback-go/pkg/types"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Source types
+const (
+	TypeJSON      = "json"
+	TypeM3U       = "m3u"
+	TypeDirectory = "dir"
+	TypeFeed      = "feed"
+)
+
+// Source provides the songs of the playlist
+type Source interface {
+	// Load returns the songs. A conditional load returns no songs and false
+	// when the playlist has not changed since the previous load, an
+	// unconditional one always reports a change
+	Load(ctx context.Context, conditional bool) ([]*types.Song, bool, error)
+}
+
+// Prober reads the tags of local audio files
+type Prober interface {
+	GetTags(url string) (map[string]string, float64, error)
+}
+
+// New creates the source of location, sourceType selects the format and is
+// detected from location when empty
+func New(sourceType string, location string, client *httpclient.Client, prober Prober) (Source, error) {
+	if sourceType == "" {
+		sourceType = Detect(location)
+	}
+
+	switch sourceType {
+	case TypeJSON:
+		return NewJSONSource(client, location), nil
+	case TypeM3U:
+		return NewM3USource(client, location), nil
+	case TypeDirectory:
+		return NewDirectorySource(localPath(location), prober), nil
+	case TypeFeed:
+		return NewFeedSource(client, location), nil
+	}
+	return nil, fmt.Errorf("unsupported playlist type: %s", sourceType)
+}
+
+// Detect guesses the source type of location from its scheme and extension
+func Detect(location string) string {
+	if !isRemote(location) {
+		if stat, err := os.Stat(localPath(location)); err == nil && stat.IsDir() {
+			return TypeDirectory
+		}
+	}
+
+	name := location
+	if u, err := url.Parse(location); err == nil && u.Path != "" {
+		name = u.Path
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".m3u", ".m3u8", ".pls":
+		return TypeM3U
+	case ".rss", ".atom", ".xml":
+		return TypeFeed
+	}
+	if isRemote(location) {
+		return TypeJSON
+	}
+	return TypeM3U
+}
+
+// isRemote checks if location is fetched over HTTP
+func isRemote(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// localPath strips the file scheme of location
+func localPath(location string) string {
+	return strings.TrimPrefix(location, "file://")
+}
+
+// open returns the content of a remote or local location
+func open(ctx context.Context, client *httpclient.Client, location string) (io.ReadCloser, error) {
+	if !isRemote(location) {
+		return os.Open(localPath(location))
+	}
+
+	resp, err := client.Get(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s returned status %d", location, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// read returns the whole content of location
+func read(ctx context.Context, client *httpclient.Client, location string) ([]byte, error) {
+	reader, err := open(ctx, client, location)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// tracker detects whether the content of a source changed between loads
+type tracker struct {
+	digest [sha256.Size]byte
+	loaded bool
+	lock   sync.Mutex
+}
+
+// changed records the digest of content and reports whether it differs from
+// the previous one
+func (t *tracker) changed(content []byte) bool {
+	digest := sha256.Sum256(content)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	changed := !t.loaded || digest != t.digest
+	t.digest = digest
+	t.loaded = true
+	return changed
+}
+
+// FallbackSource loads the first of its sources which is available, e.g. a
+// music folder on a USB stick when the web API is down
+type FallbackSource struct {
+	sources []Source
+	current int
+	lock    sync.Mutex
+}
+
+// NewFallbackSource creates a new FallbackSource trying sources in order
+func NewFallbackSource(sources ...Source) *FallbackSource {
+	return &FallbackSource{sources: sources, current: -1}
+}
+
+// Load implements Source
+func (fs *FallbackSource) Load(ctx context.Context, conditional bool) ([]*types.Song, bool, error) {
+	var errs []error
+	for i, source := range fs.sources {
+		fs.lock.Lock()
+		switched := fs.current != i
+		fs.lock.Unlock()
+
+		// A source taking over always delivers its whole playlist
+		list, changed, err := source.Load(ctx, conditional && !switched)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if switched && i > 0 {
+			logger.Logger.Info("playlist source unavailable, using fallback", i)
+		}
+		fs.lock.Lock()
+		fs.current = i
+		fs.lock.Unlock()
+		return list, changed || switched, nil
+	}
+	return nil, false, errors.Join(errs...)
+}
@@ -31,7 +31,7 @@ func (f *FFprobe) GetMediaInfo(url string) (*MediaInfo, error) {
 
 	// Parse the output to extract media information
 	// This is a simplified implementation
-	return &MediaInfo{raw: string(output)}, nil
+	return NewMediaInfo(string(output)), nil
 }
 
 // MediaInfo holds media information
@@ -39,6 +39,102 @@ type MediaInfo struct {
 	raw string
 }
 
+// NewMediaInfo creates the MediaInfo of the ffprobe output printed with
+// -show_format and -show_streams
+func NewMediaInfo(output string) *MediaInfo {
+	return &MediaInfo{raw: output}
+}
+
+// MediaDetails is the parsed ffprobe output of a media file
+type MediaDetails struct {
+	Format   string            `json:"format"`   // Short names of the container format
+	Duration float64           `json:"duration"` // Seconds
+	BitRate  int64             `json:"bit_rate,omitempty"`
+	Size     int64             `json:"size,omitempty"`
+	Streams  []StreamDetails   `json:"streams"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+// StreamDetails describes a stream of a media file
+type StreamDetails struct {
+	Index      int    `json:"index"`
+	Type       string `json:"type"` // audio, video for cover art, ...
+	Codec      string `json:"codec"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Channels   int    `json:"channels,omitempty"`
+	BitRate    int64  `json:"bit_rate,omitempty"`
+}
+
+// Details parses the format and stream sections of the output. Tag names
+// are lower case, the format tags win over the stream tags which ffprobe
+// prints first, among the streams the first value of a tag wins
+func (mi *MediaInfo) Details() *MediaDetails {
+	details := &MediaDetails{Streams: []StreamDetails{}, Tags: map[string]string{}}
+	var stream *StreamDetails
+	section := ""
+	for _, line := range strings.Split(mi.raw, "\n") {
+		line = strings.TrimSpace(line)
+		switch line {
+		case "[STREAM]":
+			section = "stream"
+			details.Streams = append(details.Streams, StreamDetails{})
+			stream = &details.Streams[len(details.Streams)-1]
+			continue
+		case "[FORMAT]":
+			section = "format"
+			continue
+		case "[/STREAM]", "[/FORMAT]":
+			section = ""
+			continue
+		}
+
+		name, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		if tag, ok := strings.CutPrefix(name, "TAG:"); ok {
+			tag = strings.ToLower(tag)
+			if section == "format" || details.Tags[tag] == "" {
+				details.Tags[tag] = strings.TrimSpace(value)
+			}
+			continue
+		}
+		if value == "N/A" {
+			continue
+		}
+
+		switch section {
+		case "format":
+			switch name {
+			case "format_name":
+				details.Format = value
+			case "duration":
+				details.Duration, _ = strconv.ParseFloat(value, 64)
+			case "bit_rate":
+				details.BitRate, _ = strconv.ParseInt(value, 10, 64)
+			case "size":
+				details.Size, _ = strconv.ParseInt(value, 10, 64)
+			}
+		case "stream":
+			switch name {
+			case "index":
+				stream.Index, _ = strconv.Atoi(value)
+			case "codec_type":
+				stream.Type = value
+			case "codec_name":
+				stream.Codec = value
+			case "sample_rate":
+				stream.SampleRate, _ = strconv.Atoi(value)
+			case "channels":
+				stream.Channels, _ = strconv.Atoi(value)
+			case "bit_rate":
+				stream.BitRate, _ = strconv.ParseInt(value, 10, 64)
+			}
+		}
+	}
+	return details
+}
+
 // GetDuration retrieves the duration of the media file
 func (mi *MediaInfo) GetDuration() (float64, error) {
 	// Parse the raw output to extract the duration
@@ -58,3 +154,19 @@ func (mi *MediaInfo) GetDuration() (float64, error) {
 
 	return 0, fmt.Errorf("duration not found in output")
 }
+
+// GetTags retrieves the tags with lower case names and the duration of the
+// media file
+func (f *FFprobe) GetTags(url string) (map[string]string, float64, error) {
+	info, err := f.GetMediaInfo(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	duration, _ := info.GetDuration()
+	return info.GetTags(), duration, nil
+}
+
+// GetTags retrieves the tags of the media file as parsed by Details
+func (mi *MediaInfo) GetTags() map[string]string {
+	return mi.Details().Tags
+}
@@ -4,21 +4,39 @@ import (
 	"mmfm-playback-go/internal/config"
 	"mmfm-playback-go/tests"
 	"os"
+	"strings"
 	"testing"
 )
 
-var conf *config.PlaybackConfig
-
 func init() {
 	tests.LoadTestEnv()
+}
+
+// loadConf loads the test configuration, the placeholders missing from the
+// environment get defaults for the duration of the test
+func loadConf(t *testing.T) *config.PlaybackConfig {
+	defaults := map[string]string{
+		"MMFM_HOST":    "mmfm.local",
+		"FFPROBE_BIN":  "/usr/bin/ffprobe",
+		"FFPROBE_PATH": "/usr/bin/ffprobe",
+		"FFPLAY_BIN":   "/usr/bin/ffplay",
+		"MPLAYER_BIN":  "mplayer",
+	}
+	for name, value := range defaults {
+		if os.Getenv(name) == "" {
+			t.Setenv(name, value)
+		}
+	}
 
-	conf, _ = config.NewConfig(tests.GetLocalPath("../config.json"))
+	conf, err := config.NewConfig(tests.GetLocalPath("../config.json"))
+	if err != nil {
+		t.Fatal("NewConfig should not return error:", err)
+	}
+	return conf
 }
 
 func TestNewFFprobe(t *testing.T) {
-	if conf == nil {
-		t.Fatal("Expected PlaybackConfig instance, got nil")
-	}
+	conf := loadConf(t)
 
 	ffprobe := NewFFprobe(conf.FFMpegConf.FFProbe)
 	if ffprobe == nil {
@@ -33,6 +51,7 @@ func TestNewFFprobe(t *testing.T) {
 }
 
 func TestMediaInfoGetDuration(t *testing.T) {
+	conf := loadConf(t)
 	ffprobe := NewFFprobe(conf.FFMpegConf.FFProbe)
 	if ffprobe == nil {
 		t.Fatal("Expected FFprobe instance, got nil")
@@ -64,3 +83,31 @@ func TestMediaInfoGetDuration(t *testing.T) {
 		t.Errorf("Expected duration to be greater than 0, got %f", duration)
 	}
 }
+
+func TestMediaInfoGetTags(t *testing.T) {
+	mediaInfo := &MediaInfo{raw: "[STREAM]\nTAG:title=Other\nTAG:genre=Pop\n[/STREAM]\n[FORMAT]\nduration=180.5\nTAG:title=Song\nTAG:ARTIST=Band\n[/FORMAT]\n"}
+
+	tags := mediaInfo.GetTags()
+	if tags["title"] != "Song" || tags["artist"] != "Band" || tags["genre"] != "Pop" {
+		t.Errorf("Expected the format tags, got %v", tags)
+	}
+}
+
+func TestMediaInfoDetails(t *testing.T) {
+	info := NewMediaInfo(strings.Join([]string{
+		"[STREAM]", "index=0", "codec_name=mp3", "codec_type=audio", "sample_rate=44100", "channels=2", "bit_rate=320000", "TAG:title=Stream Title", "[/STREAM]",
+		"[STREAM]", "index=1", "codec_name=mjpeg", "codec_type=video", "bit_rate=N/A", "[/STREAM]",
+		"[FORMAT]", "format_name=mp3", "duration=182.5", "size=7300000", "bit_rate=320000", "TAG:title=Song", "TAG:ARTIST=Band", "[/FORMAT]",
+	}, "\n"))
+
+	details := info.Details()
+	if details.Format != "mp3" || details.Duration != 182.5 || details.Size != 7300000 || details.BitRate != 320000 {
+		t.Errorf("Unexpected format details %+v", details)
+	}
+	if len(details.Streams) != 2 || details.Streams[0].SampleRate != 44100 || details.Streams[0].Channels != 2 || details.Streams[1].Type != "video" {
+		t.Errorf("Unexpected streams %+v", details.Streams)
+	}
+	if details.Tags["title"] != "Song" || details.Tags["artist"] != "Band" {
+		t.Errorf("Unexpected tags %v", details.Tags)
+	}
+}
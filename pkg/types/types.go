@@ -1,14 +1,48 @@
 package types
 
+import (
+	"fmt"
+	"strconv"
+)
+
+// Song kinds
+const (
+	// KindLive is an internet radio or live stream without a duration
+	KindLive = "live"
+)
+
 // Song represents a music track
 type Song struct {
-	Cover    string  `json:"cover"`
-	URL      string  `json:"url"`
-	Src      string  `json:"src"`
-	Name     string  `json:"name"`
-	Author   string  `json:"author"`
-	Duration float64 `json:"duration"`
-	Index    float64 `json:"index"`
+	ID       interface{} `json:"id,omitempty"`
+	Kind     string      `json:"kind,omitempty"`
+	Cover    string      `json:"cover"`
+	URL      string      `json:"url"`
+	Src      string      `json:"src"`
+	Name     string      `json:"name"`
+	Author   string      `json:"author"`
+	Duration float64     `json:"duration"`
+	Index    float64     `json:"index"`
+	// StreamTitle is the now playing title announced by a live stream
+	StreamTitle string `json:"stream_title,omitempty"`
+}
+
+// IsLive checks if the song is a live stream, its position is the elapsed
+// time and it has no duration
+func (s *Song) IsLive() bool {
+	return s.Kind == KindLive
+}
+
+// GetID returns the playlist ID of the song, empty if it has none
+func (s *Song) GetID() string {
+	switch id := s.ID.(type) {
+	case nil:
+		return ""
+	case float64:
+		// Numeric IDs must not turn into exponents
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	default:
+		return fmt.Sprint(id)
+	}
 }
 
 // GetURL returns the URL of the song, preferring URL field over Src
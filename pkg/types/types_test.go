@@ -30,3 +30,21 @@ func TestSongGetURL(t *testing.T) {
 		t.Errorf("Expected URL to be empty, got '%s'", url)
 	}
 }
+
+func TestSongGetID(t *testing.T) {
+	cases := []struct {
+		id       interface{}
+		expected string
+	}{
+		{nil, ""},
+		{"abc", "abc"},
+		{float64(12345678), "12345678"},
+	}
+
+	for _, c := range cases {
+		song := &Song{ID: c.id}
+		if id := song.GetID(); id != c.expected {
+			t.Errorf("Expected ID of %v to be '%s', got '%s'", c.id, c.expected, id)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/yobert/alsa
+
+go 1.21